@@ -3,22 +3,49 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
+var (
+	addEncrypt  bool
+	addMode     string
+	addRelative bool
+)
+
 // addCmd represents the add command
 var addCmd = &cobra.Command{
 	Use:   "add <file>",
 	Short: "Add a dotfile to tracking and create a symlink",
 	Long: `Add a dotfile to the dots directory and create a symlink from the original location.
 
+With --encrypt the file is stored encrypted (AES-256-GCM with a key derived
+from your passphrase) and the original stays in place, readable only by you.
+'dots apply' and 'dots link' decrypt it on other machines. The passphrase is
+read from DOTS_PASSPHRASE or prompted for, and must open the files encrypted
+already. The first encrypted file asks for it twice instead.
+
+With --relative the symlink holds a path relative to its own directory, so it
+keeps working when the home directory is mounted somewhere else.
+
+Paths excluded by the .dotsignore of the dots directory are not added. A
+directory holding some is added unfolded: the rest is linked file by file
+and the excluded paths stay in place.
+
+With --mode copy the original stays in place as a copy, with --mode hardlink
+it becomes a hard link to the tracked file. Use this for files that tools
+replace by renaming over them, which breaks symlinks. 'dots capture' brings
+edits made to a copy back into the repo.
+
 Example:
   dots add ~/.bashrc        # Add bashrc to tracking
   dots add ~/.config/nvim   # Add entire nvim config directory
-  dots add .zshrc           # Add from current directory`,
+  dots add .zshrc           # Add from current directory
+  dots add --encrypt ~/.netrc
+  dots add --relative ~/.bashrc
+  dots add --mode copy ~/.config/Code/User/settings.json`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filePath := args[0]
@@ -32,91 +59,35 @@ Example:
 
 func init() {
 	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().BoolVar(&addEncrypt, "encrypt", false, "Store the file encrypted instead of linking it")
+	addCmd.Flags().BoolVar(&addRelative, "relative", false, "Create a relative symlink")
+	addCmd.Flags().StringVar(&addMode, "mode", "", "Keep the original as a symlink, copy or hardlink")
 }
 
 func addDotfile(filePath string) error {
-	// Get home directory
-	home, err := os.UserHomeDir()
+	mode, err := parseModeFlag(addMode)
 	if err != nil {
-		return fmt.Errorf("cannot find home directory: %w", err)
+		return err
 	}
-
-	// Resolve to absolute path
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return fmt.Errorf("cannot resolve path: %w", err)
+	if mode != "" && mode != dots.ModeSymlink && addEncrypt {
+		return fmt.Errorf("--mode cannot be combined with --encrypt")
 	}
 
-	// Check if source file/directory exists
-	srcInfo, err := os.Lstat(absPath)
+	result, err := manager.Add(filePath, dots.AddOptions{Encrypt: addEncrypt, Mode: mode, Relative: addRelative})
 	if err != nil {
-		return fmt.Errorf("source does not exist: %s", absPath)
-	}
-
-	// Check if it's already a symlink
-	if srcInfo.Mode()&os.ModeSymlink != 0 {
-		return fmt.Errorf("source is already a symlink: %s", absPath)
-	}
-
-	// Get dots directory path
-	dotsDir := filepath.Join(home, ".config", "dots")
-
-	// Check if file is already inside dots directory (prevent recursive symlinks)
-	if strings.HasPrefix(absPath, dotsDir+string(filepath.Separator)) || absPath == dotsDir {
-		return fmt.Errorf("cannot add files from within dots directory: %s", absPath)
-	}
-
-	// check base name
-	baseName := filepath.Base(absPath)
-
-	// preserving structure of sub-dirs
-	relPath, err := filepath.Rel(home, absPath)
-	var dotsPath string
-
-	if err == nil && !filepath.IsAbs(relPath) && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
-		// preserve structure
-		dotsPath = filepath.Join(dotsDir, relPath)
-	} else {
-		// file outside home dir, preserve base name
-		dotsPath = filepath.Join(dotsDir, baseName)
-	}
-
-	// Create parent directory if needed
-	dotsParent := filepath.Dir(dotsPath)
-	if err := os.MkdirAll(dotsParent, 0o755); err != nil {
-		return fmt.Errorf("failed to create parent directory: %w", err)
-	}
-
-	// Check if destination already exists
-	if _, err := os.Lstat(dotsPath); err == nil {
-		return fmt.Errorf("dotfile already exists in dots directory: %s", dotsPath)
+		return err
 	}
 
-	// Copy file or directory to dots directory
-	if srcInfo.IsDir() {
-		if err := copyDir(absPath, dotsPath); err != nil {
-			return fmt.Errorf("failed to copy directory: %w", err)
-		}
-		fmt.Printf("Copied directory: %s -> %s\n", absPath, dotsPath)
-	} else {
-		if err := copyFile(absPath, dotsPath); err != nil {
-			return fmt.Errorf("failed to copy file: %w", err)
-		}
-		fmt.Printf("Copied file: %s -> %s\n", absPath, dotsPath)
+	if len(result.Excluded) > 0 {
+		fmt.Printf("Left %d path(s) excluded by .dotsignore in place:\n  %s\n", len(result.Excluded), strings.Join(result.Excluded, "\n  "))
 	}
-
-	// Remove original file/directory
-	if err := os.RemoveAll(absPath); err != nil {
-		return fmt.Errorf("failed to remove original: %w", err)
+	switch {
+	case addEncrypt:
+		logf("✓ Dotfile added encrypted!\n")
+	case result.Dotfile.IsCopied():
+		logf("✓ Dotfile added as a %s!\n", result.Dotfile.Mode)
+	default:
+		logf("✓ Dotfile added successfully!\n")
 	}
-
-	// creating symlink
-	if err := os.Symlink(dotsPath, absPath); err != nil {
-		return fmt.Errorf("failed to create symlink: %w", err)
-	}
-
-	fmt.Printf("Created symlink: %s -> %s\n", absPath, dotsPath)
-	fmt.Println("✓ Dotfile added successfully!")
-
 	return nil
 }
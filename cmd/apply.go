@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Ethics03/Dots/pkg/dots"
+	"github.com/spf13/cobra"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create every link declared in dots.yaml",
+	Long: `Read dots.yaml from the dots directory and create a symlink for every
+declared entry. Entries that are already linked are left untouched, so it is
+safe to run apply as often as you like.
+
+Top-level dotfiles form the default profile and are applied everywhere.
+Named profiles add entries on machines whose hostname or OS matches, or
+when selected with --profile.
+
+Example dots.yaml:
+  dotfiles:
+    - source: bashrc
+      target: ~/.bashrc
+    - source: kitty/
+      target: ~/.config/kitty
+  profiles:
+    work:
+      hosts: ["work-*"]
+      dotfiles:
+        - source: gitconfig-work
+          target: ~/.gitconfig
+
+Entries are linked unless they set a mode: "copy" copies the file to its
+target and "hardlink" hard links it. --mode sets the mode for every entry
+that does not declare one. With --relative, links hold paths relative to
+their own directory, existing absolute links are converted.
+
+Example:
+  dots apply
+  dots apply --profile work
+  dots apply --mode copy
+  dots apply --relative`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mode, err := parseModeFlag(applyMode)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := applyManifest(mode); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	applyMode     string
+	applyRelative bool
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().BoolVar(&applyRelative, "relative", false, "Create relative symlinks")
+	applyCmd.Flags().StringVar(&applyMode, "mode", "", "Deploy entries without a mode as symlink, copy or hardlink")
+}
+
+func applyManifest(mode dots.DeployMode) error {
+	result, err := manager.Apply(dots.LinkOptions{Mode: mode, Relative: applyRelative})
+	if err != nil {
+		return err
+	}
+	if len(result.Results) == 0 {
+		fmt.Printf("No dotfiles declared in %s\n", filepath.Join(manager.Dir(), "dots.yaml"))
+		return nil
+	}
+
+	applied, unchanged, failed := 0, 0, 0
+	for _, r := range result.Results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("✗ %s: %v\n", r.Dotfile.Entry.Source, r.Err)
+			failed++
+		case r.Outcome == dots.LinkCreated:
+			applied++
+		default:
+			unchanged++
+		}
+	}
+
+	fmt.Printf("\n%d applied, %d up to date, %d failed\n", applied, unchanged, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries could not be applied", failed, len(result.Results))
+	}
+
+	logf("✓ Manifest applied successfully!\n")
+	return nil
+}
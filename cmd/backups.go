@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// backupsCmd represents the backups command
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage the backups of files dots replaced or deleted",
+	Long: `Before dots overwrites or deletes anything, it saves a copy in the backup
+store in ~/.local/state/dots/backups. Use 'dots restore' to bring one back.
+
+Retention is configured with environment variables:
+  DOTS_BACKUP_KEEP   number of backups to keep (default 50, 0 keeps all)
+  DOTS_BACKUP_DAYS   days to keep backups for (default 0, no age limit)
+
+Example:
+  dots backups list
+  dots backups prune`,
+}
+
+// backupsListCmd represents the backups list command
+var backupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the backups in the backup store",
+	Long: `List every backup in the backup store, oldest first.
+
+Example:
+  dots backups list`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listBackups(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// backupsPruneCmd represents the backups prune command
+var backupsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete backups outside the retention limits",
+	Long: `Delete the backups that exceed DOTS_BACKUP_KEEP or are older than
+DOTS_BACKUP_DAYS. This also happens every time a backup is saved.
+
+Example:
+  DOTS_BACKUP_KEEP=10 dots backups prune`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pruned, err := manager.PruneBackups()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		logf("✓ Deleted %d backup(s)\n", pruned)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsPruneCmd)
+}
+
+func listBackups() error {
+	backups, err := manager.Backups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups yet")
+		return nil
+	}
+
+	fmt.Printf("%-10s  %-19s  %-9s  %9s  %s\n", "ID", "Created", "Reason", "Size", "Path")
+	for _, b := range backups {
+		fmt.Printf("%-10s  %-19s  %-9s  %9s  %s\n",
+			b.ID, b.Created.Format("2006-01-02 15:04:05"), b.Reason, formatSize(b.Size()), b.Path)
+	}
+	return nil
+}
+
+// formatSize prints a byte count for humans
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// captureCmd represents the capture command
+var captureCmd = &cobra.Command{
+	Use:   "capture [dotfile...]",
+	Short: "Pull edits made to copied dotfiles back into the repo",
+	Long: `Dotfiles deployed in copy or hardlink mode are separate files at their
+target, so edits made there do not reach the dots directory on their own.
+Capture copies the target back over the tracked file. The previous version
+is kept in the backup store.
+
+Without arguments every copied dotfile that was modified is captured.
+
+Example:
+  dots capture
+  dots capture vscode/settings.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := captureDotfiles(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(captureCmd)
+}
+
+func captureDotfiles(names []string) error {
+	captured, err := manager.Capture(names)
+	if err != nil {
+		return err
+	}
+
+	if len(captured) == 0 {
+		fmt.Println("Nothing to capture")
+		return nil
+	}
+	logf("✓ Captured %d dotfile(s), run 'dots sync' to commit them\n", len(captured))
+	return nil
+}
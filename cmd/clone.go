@@ -3,8 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -40,59 +38,34 @@ func init() {
 }
 
 func cloneDotfiles(repoURL string) error {
-	home, err := os.UserHomeDir()
+	files, err := manager.Clone(repoURL)
 	if err != nil {
-		return fmt.Errorf("cannot find home directory: %w", err)
+		return err
 	}
 
-	dotsDir := filepath.Join(home, ".config", "dots")
-
-	// Check if dots directory already exists
-	if _, err := os.Stat(dotsDir); err == nil {
-		return fmt.Errorf("dots directory already exists at %s\nRemove it first or use 'dots pull' to update", dotsDir)
-	}
-
-	fmt.Printf("Cloning dotfiles from %s...\n", repoURL)
-
-	// Clone the repository
-	cloneCmd := exec.Command("git", "clone", repoURL, dotsDir)
-	cloneCmd.Stdout = os.Stdout
-	cloneCmd.Stderr = os.Stderr
-	if err := cloneCmd.Run(); err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+	// Nothing to list when the clone was only planned
+	if dryRun {
+		return nil
 	}
 
 	fmt.Println("\n✓ Repository cloned successfully!")
 
 	// List available dotfiles
 	fmt.Println("\nAvailable dotfiles:")
-	files, err := os.ReadDir(dotsDir)
-	if err != nil {
-		return fmt.Errorf("failed to read dots directory: %w", err)
-	}
-
-	dotfileCount := 0
 	for _, file := range files {
-		name := file.Name()
-		// Skip git directory, README, and other meta files
-		if name == ".git" || name == ".gitignore" || name == "README.md" {
-			continue
-		}
-
 		fileType := "file"
 		if file.IsDir() {
 			fileType = "directory"
 		}
-		fmt.Printf("  - %s (%s)\n", name, fileType)
-		dotfileCount++
+		fmt.Printf("  - %s (%s)\n", file.Name(), fileType)
 	}
 
-	if dotfileCount == 0 {
+	if len(files) == 0 {
 		fmt.Println("  (No dotfiles found)")
 	}
 
 	fmt.Println("\nNext steps:")
-	fmt.Println("  1. Link individual files:     dots link <filename>")
+	fmt.Println("  1. Link your dotfiles:        dots link --all")
 	fmt.Println("  2. Check status:              dots status")
 	fmt.Println("  3. Edit a dotfile:            dots edit <filename>")
 
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+
 	"github.com/spf13/cobra"
 )
 
@@ -18,37 +20,33 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-	  if len(args) == 0 {
+		if len(args) == 0 {
 			fmt.Println("Error: Please provide a filename.")
 			return
 		}
 
 		editFile := args[0]
-		homedir,err := os.UserHomeDir()
-
-		if err != nil {
-			fmt.Println("Error creating file:", err)
+		dirPath := manager.Dir()
+		if err := mkdirAll(dirPath, 0755); err != nil {
+			fmt.Println("Error creating directory:", err)
 			return
 		}
 
- 		dirPath := fmt.Sprintf("%s/.config/dots", homedir)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-    fmt.Println("Error creating directory:", err)
-   		 return
-				}
+		dotpath := filepath.Join(dirPath, editFile)
+		if dryRun {
+			planf("touch %s", dotpath)
+			return
+		}
 
-		dotpath := fmt.Sprintf("%s/%s", dirPath, editFile)
 		created, err := os.Create(dotpath)
-			if err != nil {
-    		fmt.Println("Error creating file:", err)
-    		return
-	}
-			defer created.Close()
+		if err != nil {
+			fmt.Println("Error creating file:", err)
+			return
+		}
+		defer created.Close()
 
 		fmt.Printf("Created file: %s\n", dotpath)
 
-
-
 	},
 }
 
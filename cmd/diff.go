@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Ethics03/Dots/pkg/dots"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	diffRemote   bool
+	diffDeployed bool
+	diffColor    string
+)
+
+// ANSI escape codes used to colour diffs
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [dotfile...]",
+	Short: "Show what sync, pull or apply would change.",
+	Long: `Shows differences as a unified diff, in one of three modes:
+
+  (default)    the uncommitted changes in the dots directory, which is what
+               'dots sync' would commit. Untracked files are shown as added.
+  --remote     fetches origin and lists the incoming commits 'dots pull' would
+               bring in and the outgoing ones 'dots push' would send, followed
+               by what they change
+  --deployed   how copied, templated and encrypted dotfiles differ from what
+               is deployed at their targets, which is what 'dots apply' would
+               change. Pass dotfiles to compare only those.
+
+--color decides whether the output is coloured: auto colours it when writing
+to a terminal and NO_COLOR is unset, never prints a plain unified diff that
+can be saved or piped to patch.
+
+Example:
+  dots diff
+  dots diff --remote
+  dots diff --deployed
+  dots diff --deployed .gitconfig
+  dots diff --color=never > changes.patch`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if diffRemote && diffDeployed {
+			fmt.Println("Error: --remote and --deployed cannot be combined")
+			os.Exit(1)
+		}
+		if len(args) > 0 && !diffDeployed {
+			fmt.Println("Error: dotfiles can only be passed with --deployed")
+			os.Exit(1)
+		}
+
+		color, err := useColor(diffColor)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var output bytes.Buffer
+		switch {
+		case diffRemote:
+			err = diffWithRemote(&output, color)
+		case diffDeployed:
+			err = diffTargets(&output, args)
+		default:
+			err = diffWorking(&output)
+		}
+		// Show what was diffed so far, even after an error
+		writeDiff(os.Stdout, output.Bytes(), color)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&diffRemote, "remote", false, "Compare with origin: list incoming and outgoing commits and their changes")
+	diffCmd.Flags().BoolVar(&diffDeployed, "deployed", false, "Compare copied, templated and encrypted dotfiles with their targets")
+	diffCmd.Flags().StringVar(&diffColor, "color", "auto", "Colour the output: auto, always or never")
+}
+
+// useColor decides whether to colour the output from the --color flag
+func useColor(value string) (bool, error) {
+	switch value {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+		return term.IsTerminal(int(os.Stdout.Fd())), nil
+	}
+	return false, fmt.Errorf("invalid --color %q (want auto, always or never)", value)
+}
+
+func diffWorking(w io.Writer) error {
+	changes, err := manager.DiffWorking(w)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("✓ No uncommitted changes")
+	}
+	return nil
+}
+
+func diffWithRemote(w io.Writer, color bool) error {
+	rd, err := manager.DiffRemote()
+	if err != nil {
+		return err
+	}
+
+	if len(rd.Incoming) == 0 && len(rd.Outgoing) == 0 {
+		fmt.Printf("✓ Up to date with %s\n", rd.Upstream)
+		return nil
+	}
+
+	sections := []struct {
+		title    string
+		commits  []dots.Commit
+		from, to string
+	}{
+		{"Incoming from " + rd.Upstream, rd.Incoming, rd.Base, rd.Upstream},
+		{"Outgoing to " + rd.Upstream, rd.Outgoing, rd.Base, "HEAD"},
+	}
+	for _, section := range sections {
+		if len(section.commits) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s, %d commit(s):\n", section.title, len(section.commits))
+		for _, c := range section.commits {
+			hash := c.Hash
+			if len(hash) > 7 {
+				hash = hash[:7]
+			}
+			if color {
+				hash = colorYellow + hash + colorReset
+			}
+			fmt.Fprintf(w, "  %s %s (%s, %s)\n", hash, c.Subject, c.Author, c.When.Format("2006-01-02"))
+		}
+		fmt.Fprintln(w)
+		if err := manager.DiffRevisions(w, section.from, section.to); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func diffTargets(w io.Writer, names []string) error {
+	differ, err := manager.DiffDeployed(w, names)
+	if err != nil {
+		return err
+	}
+	if len(differ) == 0 {
+		fmt.Println("✓ Every deployed copy is up to date")
+	}
+	return nil
+}
+
+// writeDiff writes a unified diff, colouring added lines green, removed
+// lines red, hunk headers cyan and file headers bold
+func writeDiff(w io.Writer, diff []byte, color bool) {
+	if !color {
+		w.Write(diff)
+		return
+	}
+
+	for _, line := range strings.SplitAfter(string(diff), "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		var code string
+		switch {
+		case strings.HasPrefix(text, "diff "), strings.HasPrefix(text, "index "),
+			strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "),
+			strings.HasPrefix(text, "new file mode"), strings.HasPrefix(text, "deleted file mode"):
+			code = colorBold
+		case strings.HasPrefix(text, "@@"):
+			code = colorCyan
+		case strings.HasPrefix(text, "+"):
+			code = colorGreen
+		case strings.HasPrefix(text, "-"):
+			code = colorRed
+		}
+		if code == "" {
+			fmt.Fprint(w, line)
+			continue
+		}
+		fmt.Fprint(w, code+text+colorReset+line[len(text):])
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Ethics03/Dots/pkg/dots"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check your dots setup and suggest fixes",
+	Long: `Run every health check at once and report how to fix what is wrong.
+
+This command checks:
+  - the dots directory exists and is a git repository
+  - git is installed and a remote is configured
+  - $EDITOR resolves to an executable
+  - tracked links are not dangling and point into the dots directory
+  - no tracked file is world-writable
+  - no file in the dots directory is excluded by .gitignore
+
+Exits with status 1 when a check fails; warnings do not fail.
+
+Example:
+  dots doctor`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDoctor(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor() error {
+	fmt.Println("Checking your dots setup...")
+	fmt.Println()
+
+	checks, err := manager.Doctor()
+	if err != nil {
+		return err
+	}
+
+	failures, warnings := 0, 0
+	for _, check := range checks {
+		switch check.Level {
+		case dots.CheckOK:
+			fmt.Printf("✓ %s\n", check.Message)
+			continue
+		case dots.CheckWarn:
+			warnings++
+			fmt.Printf("⚠ %s\n", check.Message)
+		case dots.CheckFail:
+			failures++
+			fmt.Printf("✗ %s\n", check.Message)
+		}
+		fmt.Printf("    → %s\n", check.Fix)
+	}
+
+	fmt.Println()
+	if failures > 0 {
+		return fmt.Errorf("%d problem(s) and %d warning(s) found", failures, warnings)
+	}
+	if warnings > 0 {
+		fmt.Printf("No problems, %d warning(s)\n", warnings)
+		return nil
+	}
+	fmt.Println("✓ Everything looks good!")
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// dryRun is set by the persistent --dry-run flag. When enabled, every
+// filesystem and git mutation is printed instead of executed.
+var dryRun bool
+
+// planf prints a planned action in dry-run mode
+func planf(format string, args ...any) {
+	fmt.Printf("[dry-run] "+format+"\n", args...)
+}
+
+// logf prints progress that only makes sense once an action really happened
+func logf(format string, args ...any) {
+	if !dryRun {
+		fmt.Printf(format, args...)
+	}
+}
+
+func mkdirAll(path string, perm os.FileMode) error {
+	if dryRun {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			planf("mkdir -p %s", path)
+		}
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// cliLogger prints the progress of the manager to stdout
+type cliLogger struct{}
+
+func (cliLogger) Infof(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func (cliLogger) Warnf(format string, args ...any) {
+	fmt.Printf("⚠ "+format+"\n", args...)
+}
+
+func (cliLogger) Planf(format string, args ...any) {
+	planf(format, args...)
+}
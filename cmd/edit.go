@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
@@ -13,35 +16,96 @@ var editCmd = &cobra.Command{
 	Use:   "edit",
 	Short: "edit command lets you edit the dotfile mentioned",
 	Long: `Provide the filepath/filename of the dotfile you want to edit, it won't
-	apply any changes until you do "dots save"`,
+	apply any changes until you do "dots save"
+
+The dotfile can be named by its path in the dots directory, its path in your
+home directory, or just the end of its path as long as only one dotfile
+matches.
+
+Example:
+  dots edit .bashrc
+  dots edit ~/.config/nvim/init.lua
+  dots edit nvim/init.lua`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		fileEdit := args[0]
 
 		// Find the dotfile in dots directory
-		dotPath, _, err := findDotfile(fileEdit)
+		df, err := manager.Find(fileEdit)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			return
 		}
+		dotPath := df.Source
 
-		editor := os.Getenv("EDITOR")
-		if editor == "" {
-			editor = "vim" //fallback EDITOR
+		if df.IsEncrypted() {
+			if err := editEncrypted(*df); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
 		}
 
-		editcmd := exec.Command(editor, dotPath)
-		editcmd.Stdin = os.Stdin
-		editcmd.Stdout = os.Stdout
-		editcmd.Stderr = os.Stderr
-
-		if err := editcmd.Run(); err != nil {
+		if err := runEditor(dotPath); err != nil {
 			fmt.Println("failed to open editor: ", err)
 		}
 
 	},
 }
 
+func runEditor(path string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vim" //fallback EDITOR
+	}
+
+	editcmd := exec.Command(editor, path)
+	editcmd.Stdin = os.Stdin
+	editcmd.Stdout = os.Stdout
+	editcmd.Stderr = os.Stderr
+	return editcmd.Run()
+}
+
+// editEncrypted decrypts the dotfile into a private temp file, opens it in
+// the editor and re-encrypts it if anything changed
+func editEncrypted(df dots.Dotfile) error {
+	plaintext, err := manager.Decrypt(df)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "dots-edit-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Keep the original name so the editor picks the right file type
+	tmpPath := filepath.Join(tmpDir, filepath.Base(df.Target))
+	if err := os.WriteFile(tmpPath, plaintext, 0o600); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := runEditor(tmpPath); err != nil {
+		return fmt.Errorf("failed to open editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read temp file: %w", err)
+	}
+	if bytes.Equal(edited, plaintext) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	if err := manager.Encrypt(df, edited); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Re-encrypted %s\n", df.Source)
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(editCmd)
 
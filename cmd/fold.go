@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// foldCmd represents the fold command
+var foldCmd = &cobra.Command{
+	Use:   "fold <directory>",
+	Short: "Link a tracked directory as a whole",
+	Long: `A tracked directory is either folded, a single symlink to the directory in
+the dots directory, or unfolded, a real directory holding a symlink per file.
+
+Fold replaces the per-file links of an unfolded directory with one link.
+Files in it that dots does not track stop fold, move them into the dots
+directory with 'dots add' first. So do paths in the dots directory excluded
+by .dotsignore, the link would expose them. Folded directories are listed under
+'folded' in dots.yaml, so every machine links them the same way.
+
+Example:
+  dots fold .config/nvim
+  dots fold ~/.config/nvim`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := foldDirectory(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// unfoldCmd represents the unfold command
+var unfoldCmd = &cobra.Command{
+	Use:   "unfold <directory>",
+	Short: "Link the files of a tracked directory one by one",
+	Long: `Unfold replaces the link to a folded directory with a real directory
+holding a symlink per file, so other programs can keep their own files next
+to the tracked ones. Paths excluded by .dotsignore are not linked.
+
+Example:
+  dots unfold .config/nvim`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := unfoldDirectory(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(foldCmd)
+	rootCmd.AddCommand(unfoldCmd)
+}
+
+func foldDirectory(name string) error {
+	result, err := manager.Fold(name)
+	if err != nil {
+		return err
+	}
+
+	df := result.Dotfile
+	if !result.Changed {
+		fmt.Printf("✓ Already folded: %s -> %s\n", df.Target, df.Source)
+		return nil
+	}
+	logf("✓ Folded %s -> %s\n", df.Target, df.Source)
+	return nil
+}
+
+func unfoldDirectory(name string) error {
+	result, err := manager.Unfold(name)
+	if err != nil {
+		return err
+	}
+
+	df := result.Dotfile
+	if !result.Changed {
+		fmt.Printf("✓ Already unfolded: %s\n", df.Target)
+		return nil
+	}
+	logf("✓ Unfolded %s\n", df.Target)
+	if result.Generated > 0 {
+		relPath, _ := filepath.Rel(manager.Dir(), df.Source)
+		fmt.Printf("Run 'dots link %s' to render or decrypt the %d generated file(s) in it\n", relPath, result.Generated)
+	}
+	return nil
+}
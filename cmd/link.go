@@ -4,54 +4,120 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
+var (
+	linkAll        bool
+	linkOnConflict string
+	linkMode       string
+	linkRelative   bool
+)
+
 // linkCmd represents the link command
 var linkCmd = &cobra.Command{
-	Use:   "link",
+	Use:   "link [dotfile...]",
 	Short: "Create symlinks for tracked dotfiles.",
-	Long:  `Creates symbolic links from your dotfiles repo to their original paths.`,
+	Long: `Creates symbolic links from your dotfiles repo to their original paths.
+
+Pass one or more dotfiles, glob patterns matched against paths in the dots
+directory, or --all to link everything that is tracked.
+
+When something already exists at a target, --on-conflict decides what to do:
+  skip        leave it alone and report the conflict (default)
+  backup      move it to the backup store in ~/.local/state/dots/backups
+  overwrite   delete it
+  adopt       copy it into the repo, replacing the tracked version
+  diff        show the differences and ask what to do
+
+Dotfiles are symlinked unless dots.yaml declares a mode for them. --mode
+copy or --mode hardlink deploys the others as copies or hard links instead,
+for tools that replace files by renaming over them. Later runs remember it.
+
+With --relative, links hold paths relative to their own directory, so they
+keep working when the home directory is mounted elsewhere. Existing absolute
+links to the right dotfile are converted.
+
+Example:
+  dots link bashrc
+  dots link bashrc zshrc 'nvim/*'
+  dots link --all
+  dots link --all --on-conflict=backup
+  dots link bashrc --on-conflict=diff
+  dots link --all --mode copy
+  dots link --all --relative`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) != 1 {
-			fmt.Println("Usage: dots link <dotfile>")
+		if len(args) == 0 && !linkAll {
+			fmt.Println("Usage: dots link <dotfile>... | --all")
 			fmt.Println("Example: dots link bashrc")
 			return
 		}
 
-		name := args[0]
-
-		// Find the dotfile in dots directory
-		src, desti, err := findDotfile(name)
+		onConflict, err := dots.ParseConflictStrategy(linkOnConflict)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			return
+			os.Exit(1)
 		}
 
-		if _, err := os.Lstat(desti); err == nil {
-			fmt.Printf("Destination already exists: %s (skipping)\n", desti)
-			return
+		mode, err := parseModeFlag(linkMode)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		err = os.Symlink(src, desti)
-		if err != nil {
-			fmt.Printf("Failed to link: %s -> %s: %v\n", src, desti, err)
-		} else {
-			fmt.Printf("Linked %s -> %s\n", src, desti)
+		if err := linkDotfiles(args, onConflict, mode); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(linkCmd)
+	linkCmd.Flags().BoolVarP(&linkAll, "all", "a", false, "Link every tracked dotfile")
+	linkCmd.Flags().StringVar(&linkOnConflict, "on-conflict", string(dots.ConflictSkip), "What to do when a target exists: skip, backup, overwrite, adopt or diff")
+	linkCmd.Flags().BoolVar(&linkRelative, "relative", false, "Create relative symlinks")
+	linkCmd.Flags().StringVar(&linkMode, "mode", "", "Deploy dotfiles without a mode as symlink, copy or hardlink")
+}
 
-	// Here you will define your flags and configuration settings.
+func linkDotfiles(names []string, onConflict dots.ConflictStrategy, mode dots.DeployMode) error {
+	results, err := manager.Link(names, dots.LinkOptions{
+		All:        linkAll,
+		Mode:       mode,
+		Relative:   linkRelative,
+		OnConflict: onConflict,
+		Ask:        askConflict,
+	})
+	if err != nil {
+		return err
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// linkCmd.PersistentFlags().String("foo", "", "A help for foo")
+	linked, unchanged, conflicts, failed := 0, 0, 0, 0
+	for _, r := range results {
+		switch r.Outcome {
+		case dots.LinkConflict:
+			fmt.Printf("⚠ Conflict: %v (skipping)\n", r.Err)
+			conflicts++
+		case dots.LinkFailed:
+			fmt.Printf("✗ %s: %v\n", r.Dotfile.Source, r.Err)
+			failed++
+		case dots.LinkCreated:
+			linked++
+		default:
+			unchanged++
+		}
+	}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// linkCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	// A single dotfile needs no summary
+	if len(results) > 1 {
+		fmt.Printf("\n%d linked, %d already linked, %d conflicting, %d failed\n", linked, unchanged, conflicts, failed)
+	}
+	if conflicts > 0 {
+		fmt.Println("Use --on-conflict=backup|overwrite|adopt|diff to resolve conflicts")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dotfiles could not be linked", failed, len(results))
+	}
+	return nil
 }
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Ethics03/Dots/pkg/dots"
+	"golang.org/x/term"
+)
+
+// stdin is shared by all prompts so buffered answers are not lost
+var stdin = bufio.NewReader(os.Stdin)
+
+// parseModeFlag parses the value of a --mode flag, empty when it is not set
+func parseModeFlag(value string) (dots.DeployMode, error) {
+	if value == "" {
+		return "", nil
+	}
+	return dots.ParseDeployMode(value)
+}
+
+// askConflict shows how the target of df differs and asks what to do, it is
+// used for --on-conflict diff
+func askConflict(df dots.Dotfile) dots.ConflictStrategy {
+	fmt.Printf("\nConflict at %s:\n", df.Target)
+	if err := manager.Diff(os.Stdout, df); err != nil {
+		fmt.Printf("⚠ %v\n", err)
+	}
+	return promptConflict(df.Target)
+}
+
+// promptConflict asks which strategy to use for a single conflict. Anything
+// unexpected, including the end of input, skips it.
+func promptConflict(target string) dots.ConflictStrategy {
+	fmt.Printf("%s: [s]kip, [b]ackup, [o]verwrite or [a]dopt? ", target)
+
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return dots.ConflictSkip
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "b", "backup":
+		return dots.ConflictBackup
+	case "o", "overwrite":
+		return dots.ConflictOverwrite
+	case "a", "adopt":
+		return dots.ConflictAdopt
+	}
+	return dots.ConflictSkip
+}
+
+// promptPassphrase asks for the passphrase of encrypted dotfiles on the
+// terminal. With confirm set it has to be typed twice.
+func promptPassphrase(confirm bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("%w: set DOTS_PASSPHRASE or run in a terminal", dots.ErrNoPassphrase)
+	}
+
+	fmt.Print("Passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	if confirm {
+		fmt.Print("Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// selectChanges lists the changes sync found and asks which to commit, it is
+// used for sync -i. The end of input selects nothing.
+func selectChanges(changes []dots.Change) ([]dots.Change, error) {
+	fmt.Println("\nChanged files:")
+	for i, change := range changes {
+		path := change.Path
+		if change.From != "" {
+			path = change.From + " -> " + change.Path
+		}
+		fmt.Printf("  %2d) %-10s %s\n", i+1, change.Kind, path)
+	}
+
+	for {
+		fmt.Print("Commit which files? [a]ll, [n]one or numbers such as 1 3-4 (default all): ")
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Println()
+			return nil, nil
+		}
+
+		picked, err := parseSelection(answer, len(changes))
+		if err != nil {
+			fmt.Printf("⚠ %v\n", err)
+			continue
+		}
+
+		var selected []dots.Change
+		for _, i := range picked {
+			selected = append(selected, changes[i])
+		}
+		return selected, nil
+	}
+}
+
+// parseSelection parses an answer to selectChanges into indexes into a list
+// of n items, in list order
+func parseSelection(answer string, n int) ([]int, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	chosen := make([]bool, n)
+	switch answer {
+	case "", "a", "all":
+		for i := range chosen {
+			chosen[i] = true
+		}
+	case "n", "none":
+	default:
+		for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+			first, last, isRange := strings.Cut(field, "-")
+			from, err := strconv.Atoi(first)
+			to := from
+			if err == nil && isRange {
+				to, err = strconv.Atoi(last)
+			}
+			if err != nil || from < 1 || to > n || from > to {
+				return nil, fmt.Errorf("invalid selection %q, pick numbers from 1 to %d", field, n)
+			}
+			for i := from; i <= to; i++ {
+				chosen[i-1] = true
+			}
+		}
+	}
+
+	var picked []int
+	for i, ok := range chosen {
+		if ok {
+			picked = append(picked, i)
+		}
+	}
+	return picked, nil
+}
+
+// askPullConflict asks how to settle a conflict of a pull. Anything
+// unexpected asks again, the end of input aborts the pull.
+func askPullConflict(conflict dots.PullConflict) dots.Resolution {
+	fmt.Printf("\nConflict in %s", conflict.Path)
+	if conflict.Target != "" {
+		fmt.Printf(" (deployed at %s)", conflict.Target)
+	}
+	fmt.Println(":")
+	fmt.Println("  ours    keep your local version")
+	fmt.Println("  theirs  take the incoming version")
+	fmt.Println("  abort   give up the pull and leave everything as it was")
+
+	for {
+		fmt.Print("[o]urs, [t]heirs or [a]bort? ")
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Println()
+			return dots.ResolveAbort
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "o", "ours":
+			return dots.ResolveOurs
+		case "t", "theirs":
+			return dots.ResolveTheirs
+		case "a", "abort":
+			return dots.ResolveAbort
+		}
+	}
+}
+
+// confirmRelink asks before pull follows an added, renamed or deleted
+// dotfile, it is used for pull --relink=ask. The end of input declines.
+func confirmRelink(r dots.Relink) bool {
+	switch r.Kind {
+	case dots.RelinkLink:
+		fmt.Printf("Link new dotfile %s -> %s? [Y/n] ", r.Dotfile.Target, r.Dotfile.Source)
+	case dots.RelinkMove:
+		fmt.Printf("Move link %s to %s? [Y/n] ", r.From.Target, r.Dotfile.Target)
+	case dots.RelinkUnlink:
+		fmt.Printf("Remove dangling link %s? [Y/n] ", r.Dotfile.Target)
+	}
+
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true
+	}
+	return false
+}
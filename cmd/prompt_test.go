@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		answer  string
+		n       int
+		want    []int
+		wantErr bool
+	}{
+		{answer: "", n: 3, want: []int{0, 1, 2}},
+		{answer: "a", n: 2, want: []int{0, 1}},
+		{answer: " ALL\n", n: 2, want: []int{0, 1}},
+		{answer: "n", n: 3, want: nil},
+		{answer: "none", n: 3, want: nil},
+		{answer: "2", n: 3, want: []int{1}},
+		{answer: "3,1", n: 3, want: []int{0, 2}},
+		{answer: "1 3", n: 3, want: []int{0, 2}},
+		{answer: "1, 3", n: 3, want: []int{0, 2}},
+		{answer: "2-4", n: 5, want: []int{1, 2, 3}},
+		{answer: "1-2,2-3", n: 3, want: []int{0, 1, 2}},
+		{answer: "5,1-2", n: 5, want: []int{0, 1, 4}},
+		{answer: "0", n: 3, wantErr: true},
+		{answer: "4", n: 3, wantErr: true},
+		{answer: "3-2", n: 3, wantErr: true},
+		{answer: "1-4", n: 3, wantErr: true},
+		{answer: "-2", n: 3, wantErr: true},
+		{answer: "x", n: 3, wantErr: true},
+		{answer: "1,x", n: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSelection(tt.answer, tt.n)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSelection(%q, %d) = %v, want an error", tt.answer, tt.n, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSelection(%q, %d): %v", tt.answer, tt.n, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q, %d) = %v, want %v", tt.answer, tt.n, got, tt.want)
+		}
+	}
+}
@@ -3,12 +3,17 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
+var (
+	pullStrategy   string
+	pullOnConflict string
+	pullRelink     string
+)
+
 // pullCmd represents the pull command
 var pullCmd = &cobra.Command{
 	Use:   "pull",
@@ -17,10 +22,40 @@ var pullCmd = &cobra.Command{
 
 This command will:
   - Fetch changes from the remote repository
+  - Check that they can be brought in before touching anything
+  - Stash uncommitted changes and bring them back afterwards
   - Merge them into your local dotfiles
 
+When you have commits that the remote does not, history has diverged and
+--strategy decides what happens:
+  ff-only   refuse to pull (default)
+  rebase    replay your commits on top of the incoming ones
+  merge     join both with a merge commit
+
+Uncommitted changes to files the incoming commits change stop the pull, commit
+them with 'dots sync' first. When a rebase or merge stops at a conflict, every
+conflicting dotfile is settled as --on-conflict says:
+  ask       show the choices and ask (default)
+  ours      keep your local version
+  theirs    take the incoming version
+  abort     give up the pull and leave everything as it was
+
+If the pull fails, your stashed changes stay in the stash.
+
+Afterwards the targets follow what the incoming commits did to your dotfiles:
+new ones are linked, the links of renamed ones are moved and the dangling
+links of deleted ones are removed. --relink decides how:
+  auto      do all of it (default)
+  ask       ask before each change
+  never     leave the targets alone
+
+Run 'dots diff --remote' first to review the incoming commits.
+
 Example:
-  dots pull`,
+  dots pull
+  dots pull --strategy=rebase
+  dots pull --strategy=merge --on-conflict=theirs
+  dots pull --relink=ask`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := pullDotfiles(); err != nil {
 			fmt.Printf("Error: %v\n", err)
@@ -31,79 +66,63 @@ Example:
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().StringVar(&pullStrategy, "strategy", string(dots.PullFastForward), "How to combine diverged history: ff-only, rebase or merge")
+	pullCmd.Flags().StringVar(&pullOnConflict, "on-conflict", "ask", "How to settle conflicts: ask, ours, theirs or abort")
+	pullCmd.Flags().StringVar(&pullRelink, "relink", "auto", "Follow added, renamed and deleted dotfiles: auto, ask or never")
 }
 
 func pullDotfiles() error {
-	home, err := os.UserHomeDir()
+	strategy, err := dots.ParsePullStrategy(pullStrategy)
 	if err != nil {
-		return fmt.Errorf("cannot find home directory: %w", err)
+		return err
 	}
 
-	dotsDir := filepath.Join(home, ".config", "dots")
-
-	// Check if dots directory exists
-	if _, err := os.Stat(dotsDir); os.IsNotExist(err) {
-		return fmt.Errorf("dots directory not found. Run 'dots init' first")
+	resolve := askPullConflict
+	if pullOnConflict != "ask" {
+		resolution, err := dots.ParseResolution(pullOnConflict)
+		if err != nil {
+			return fmt.Errorf("invalid --on-conflict '%s' (want ask, ours, theirs or abort)", pullOnConflict)
+		}
+		resolve = func(dots.PullConflict) dots.Resolution { return resolution }
 	}
 
-	// Check if it's a git repository
-	gitDir := filepath.Join(dotsDir, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		return fmt.Errorf("not a git repository. Run 'dots init' to initialize")
+	opts := dots.PullOptions{Strategy: strategy, Resolve: resolve}
+	switch pullRelink {
+	case "auto":
+	case "ask":
+		opts.ConfirmRelink = confirmRelink
+	case "never":
+		opts.NoRelink = true
+	default:
+		return fmt.Errorf("invalid --relink '%s' (want auto, ask or never)", pullRelink)
 	}
 
-	// Check if remote is configured
-	remoteCmd := exec.Command("git", "remote", "get-url", "origin")
-	remoteCmd.Dir = dotsDir
-	if output, err := remoteCmd.Output(); err != nil || len(output) == 0 {
-		return fmt.Errorf("no remote repository configured\nAdd a remote with: cd %s && git remote add origin <url>", dotsDir)
+	result, err := manager.Pull(opts)
+	if result != nil && result.StashErr != nil {
+		fmt.Printf("Warning: Your uncommitted changes were not applied again (%v), they are kept in the stash\n", result.StashErr)
+		fmt.Printf("You can manually apply them with: cd %s && git stash pop\n", manager.Dir())
 	}
-
-	fmt.Println("Pulling changes from remote...")
-
-	// Check for uncommitted changes
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	statusCmd.Dir = dotsDir
-	output, err := statusCmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to check git status: %w", err)
+		return err
+	}
+	if len(result.Incoming) == 0 {
+		return nil
 	}
 
-	if len(output) > 0 {
-		fmt.Println("Warning: You have uncommitted changes")
-		fmt.Println("Stashing changes before pull...")
-
-		// Stash changes
-		stashCmd := exec.Command("git", "stash", "push", "-m", "Auto-stash before pull")
-		stashCmd.Dir = dotsDir
-		if output, err := stashCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to stash changes: %w\n%s", err, output)
+	pending := 0
+	for _, r := range result.Relinks {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("⚠ Could not %s %s: %v\n", r.Kind, r.Dotfile.Target, r.Err)
+			pending++
+		case !r.Done:
+			pending++
 		}
-		fmt.Println("✓ Changes stashed")
-
-		defer func() {
-			fmt.Println("\nApplying stashed changes...")
-			popCmd := exec.Command("git", "stash", "pop")
-			popCmd.Dir = dotsDir
-			if output, err := popCmd.CombinedOutput(); err != nil {
-				fmt.Printf("Warning: Failed to apply stashed changes: %v\n%s\n", err, output)
-				fmt.Println("You can manually apply them with: cd ~/.config/dots && git stash pop")
-			} else {
-				fmt.Println("✓ Stashed changes applied")
-			}
-		}()
 	}
 
-	// Pull changes
-	pullCmd := exec.Command("git", "pull")
-	pullCmd.Dir = dotsDir
-	pullCmd.Stdout = os.Stdout
-	pullCmd.Stderr = os.Stderr
-	if err := pullCmd.Run(); err != nil {
-		return fmt.Errorf("failed to pull: %w", err)
+	logf("\n✓ Dotfiles pulled successfully!\n")
+	if pending > 0 && !manager.DryRun() {
+		fmt.Printf("\n%d added, renamed or deleted dotfile(s) were left as they are, check them with 'dots status'\n", pending)
 	}
-
-	fmt.Println("\n✓ Dotfiles pulled successfully!")
-	fmt.Println("\nNote: You may need to run 'dots status' to check symlink status")
 	return nil
 }
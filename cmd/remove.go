@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -19,11 +18,13 @@ This command will:
   - Remove the symlink from the original location
   - Copy the file back from ~/.config/dots
   - Delete the file from ~/.config/dots
+  - Remove its entry from dots.yaml, if it is declared there
 
 Example:
   dots remove bashrc
   dots remove .zshrc
-  dots remove .config/nvim`,
+  dots remove .config/nvim
+  dots remove ~/.ssh/config`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filename := args[0]
@@ -40,75 +41,10 @@ func init() {
 }
 
 func removeDotfile(filename string) error {
-	// Find the dotfile in dots directory
-	dotsPath, homePath, err := findDotfile(filename)
-	if err != nil {
+	if _, err := manager.Remove(filename); err != nil {
 		return err
 	}
 
-	// Check if file exists in dots directory
-	dotsInfo, err := os.Stat(dotsPath)
-	if err != nil {
-		return fmt.Errorf("failed to access dots file: %w", err)
-	}
-
-	// Check if symlink exists
-	linkInfo, err := os.Lstat(homePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Symlink doesn't exist, just remove from dots directory
-			fmt.Printf("⚠ Warning: No symlink found at %s\n", homePath)
-			fmt.Println("Removing from dots directory only...")
-		} else {
-			return fmt.Errorf("failed to check symlink: %w", err)
-		}
-	} else {
-		// Check if it's actually a symlink
-		if linkInfo.Mode()&os.ModeSymlink != 0 {
-			// Verify it points to our dots file
-			linkTarget, err := os.Readlink(homePath)
-			if err != nil {
-				return fmt.Errorf("failed to read symlink: %w", err)
-			}
-
-			absLinkTarget, _ := filepath.Abs(linkTarget)
-			absDotsPath, _ := filepath.Abs(dotsPath)
-
-			if absLinkTarget != absDotsPath {
-				return fmt.Errorf("symlink at %s points to %s, not %s\nManual intervention required",
-					homePath, linkTarget, dotsPath)
-			}
-
-			// Remove the symlink
-			if err := os.Remove(homePath); err != nil {
-				return fmt.Errorf("failed to remove symlink: %w", err)
-			}
-			fmt.Printf("✓ Removed symlink: %s\n", homePath)
-
-			// Copy file/directory back from dots to home
-			if dotsInfo.IsDir() {
-				if err := copyDir(dotsPath, homePath); err != nil {
-					return fmt.Errorf("failed to restore directory: %w", err)
-				}
-				fmt.Printf("✓ Restored directory: %s\n", homePath)
-			} else {
-				if err := copyFile(dotsPath, homePath); err != nil {
-					return fmt.Errorf("failed to restore file: %w", err)
-				}
-				fmt.Printf("✓ Restored file: %s\n", homePath)
-			}
-		} else {
-			// Not a symlink, something else exists there
-			return fmt.Errorf("file at %s exists but is not a symlink\nManual intervention required", homePath)
-		}
-	}
-
-	// Remove from dots directory
-	if err := os.RemoveAll(dotsPath); err != nil {
-		return fmt.Errorf("failed to remove from dots directory: %w", err)
-	}
-	fmt.Printf("✓ Removed from dots directory: %s\n", dotsPath)
-
-	fmt.Println("\n✓ Dotfile removed successfully!")
+	logf("\n✓ Dotfile removed successfully!\n")
 	return nil
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <id|path>",
+	Short: "Restore a file from the backup store",
+	Long: `Put a backed up file or directory back at its original location.
+
+Pass a backup ID from 'dots backups list' (a unique prefix is enough), or a
+path to restore its most recent backup. Whatever is at the location now is
+backed up itself before it is replaced.
+
+Example:
+  dots restore 3fa9c2d1
+  dots restore ~/.bashrc`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := manager.FindBackup(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := manager.RestoreBackup(b); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		logf("✓ Restored %s from backup %s (%s)\n", b.Path, b.ID, b.Created.Format("2006-01-02 15:04:05"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -1,11 +1,23 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
+// manager carries out every command, it is set up before any of them runs
+var manager *dots.Manager
+
+// Values of the persistent flags
+var (
+	dotsDirFlag string
+	profileFlag []string
+	noHooks     bool
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "dots",
@@ -13,6 +25,28 @@ var rootCmd = &cobra.Command{
 	Long: `Dots is a minimal, fast, and developer-friendly dotfile manager built in Go.
 It helps you effortlessly manage, version, and sync your dotfiles using symlinks and Git,
 without the complexity of bloated tools or manual setup..`,
+	// Set up the manager and finish or undo any transaction an interrupted
+	// run left behind
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		var err error
+		manager, err = dots.New(dots.Options{
+			Dir:        dotsDirFlag,
+			Profiles:   profileFlag,
+			DryRun:     dryRun,
+			Logger:     cliLogger{},
+			GitOutput:  os.Stdout,
+			NoHooks:    noHooks,
+			HookOutput: os.Stdout,
+			Passphrase: promptPassphrase,
+		})
+		if err == nil {
+			err = manager.Recover()
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -33,6 +67,10 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.Dots.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the planned changes without executing them")
+	rootCmd.PersistentFlags().StringSliceVar(&profileFlag, "profile", nil, "profiles to use instead of matching on hostname and OS (default is $DOTS_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&dotsDirFlag, "dir", "", "dots directory (default is $DOTS_DIR, $XDG_CONFIG_HOME/dots or ~/.config/dots)")
+	rootCmd.PersistentFlags().BoolVar(&noHooks, "no-hooks", false, "skip the hooks declared in dots.yaml and the hooks directory")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
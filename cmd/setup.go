@@ -30,22 +30,18 @@ func init() {
 }
 
 func setupDirStructure(path string) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home dir: %w ", err)
-	}
-
-	fullpath := filepath.Join(homeDir, ".config", "dots", ".config", path)
+	dotsDir := manager.Dir()
+	fullpath := filepath.Join(dotsDir, ".config", path)
 
 	if _, err := os.Stat(fullpath); err == nil {
-		fmt.Printf("Directory already exists: ~/.config/dots/.config/%s\n", path)
+		fmt.Printf("Directory already exists: %s\n", fullpath)
 		return nil
 	}
 
-	if err := os.MkdirAll(fullpath, 0o755); err != nil {
-		return fmt.Errorf("Failed to create directory: %w\n", path)
+	if err := mkdirAll(fullpath, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	fmt.Printf("Setup Done: ~/.config/dots/.config/%s\n", path)
+	logf("Setup Done: %s\n", fullpath)
 	return nil
 }
@@ -1,97 +1,147 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/user"
-	"path/filepath"
 
+	"github.com/Ethics03/Dots/pkg/dots"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusJSON      bool
+	statusPorcelain bool
+)
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "A command to check the status of the dots folder",
 	Long: `Helps you in checking the current status of all the symlinks and the files
-	connected through those symlinks to your dotfiles`,
+connected through those symlinks to your dotfiles.
+
+Every tracked dotfile is reported as one of:
+  ok              the link points to the dotfile
+  missing         there is nothing at the link location
+  wrong-target    the link points somewhere else
+  not-a-symlink   a regular file or directory is in the way
+  orphaned        the dotfile no longer exists in the dots directory
+  stale           a rendered template, decrypted file or copy is out of date,
+                  run 'dots apply'
+  modified        a copy was edited at its target, run 'dots capture'
+
+Exits with status 1 when anything is out of place.
+
+Example:
+  dots status
+  dots status --json
+  dots status --porcelain`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		usr, _ := user.Current()
-		dotDr := filepath.Join(usr.HomeDir, ".config", "dots")
+		clean, err := showStatus()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !clean {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the status as JSON")
+	statusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "Print the status in a stable, script-friendly format")
+}
 
-		// Check if dots directory exists
-		if _, err := os.Stat(dotDr); os.IsNotExist(err) {
-			fmt.Println("Dots directory not found. Run 'dots init' first.")
-			return
+// showStatus prints the status of every tracked dotfile and reports whether
+// all of them are in place
+func showStatus() (bool, error) {
+	entries, err := manager.Status()
+	if err != nil {
+		return false, err
+	}
+
+	clean := true
+	for _, entry := range entries {
+		if entry.State != dots.StateOK {
+			clean = false
+		}
+	}
+
+	switch {
+	case statusJSON:
+		output := struct {
+			DotsDir string             `json:"dots_dir"`
+			Clean   bool               `json:"clean"`
+			Entries []dots.StatusEntry `json:"entries"`
+		}{manager.Dir(), clean, entries}
+		if output.Entries == nil {
+			output.Entries = []dots.StatusEntry{}
 		}
 
-		fmt.Println("Dotfiles status: ")
-		fmt.Printf("%-40s  ->  %s\n", "Dotfile (home)", "Target (./.config/dots folder)")
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return false, fmt.Errorf("failed to encode status: %w", err)
+		}
+	case statusPorcelain:
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\t%s\n", entry.State, entry.Target, entry.Source)
+		}
+	default:
+		printStatus(entries)
+	}
 
-		// Walk the dots directory to find all dotfiles (including nested ones)
-		filepath.Walk(dotDr, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
+	return clean, nil
+}
 
-			// Skip directories
-			if info.IsDir() {
-				return nil
-			}
+func printStatus(entries []dots.StatusEntry) {
+	fmt.Println("Dotfiles status: ")
+	fmt.Printf("%-40s  ->  %s\n", "Dotfile (home)", "Target (dots folder)")
 
-			filename := info.Name()
-			// Skip git directory and meta files
-			if filename == ".git" || filename == ".gitignore" || filename == "README.md" {
-				return nil
+	for _, entry := range entries {
+		switch entry.State {
+		case dots.StateOK:
+			if entry.Mode == dots.ModeHardlink {
+				fmt.Printf("%-40s  ->  %s\n", "Hard link ok: "+entry.Target, entry.Source)
+				continue
 			}
-
-			// Get relative path from dots directory
-			relPath, err := filepath.Rel(dotDr, path)
-			if err != nil {
-				return nil
+			if entry.Mode == dots.ModeCopy {
+				fmt.Printf("%-40s  ->  %s\n", "Copy ok: "+entry.Target, entry.Source)
+				continue
 			}
-
-			// Skip if inside .git directory
-			if len(relPath) > 4 && relPath[:4] == ".git" {
-				return nil
+			if entry.Rendered {
+				fmt.Printf("%-40s  ->  %s\n", "Rendered ok: "+entry.Target, entry.Source)
+				continue
 			}
-
-			dotPath := path
-			homePath := filepath.Join(usr.HomeDir, relPath)
-
-			link, err := os.Readlink(homePath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					fmt.Printf("%-40s  ->  %s\n", "Missing symlink: "+homePath, dotPath)
-				} else {
-					fmt.Printf("%-40s  ->  %s\n", "Not a symlink or unreadable: "+homePath, "")
-				}
-				return nil
+			fmt.Printf("%-40s  ->  %s\n", "Status ok: "+entry.Target, entry.Link)
+		case dots.StateMissing:
+			if entry.Mode != "" {
+				fmt.Printf("%-40s  ->  %s\n", "Missing "+string(entry.Mode)+": "+entry.Target, entry.Source)
+				continue
 			}
-			absTarget, _ := filepath.Abs(dotPath)
-			absLink, _ := filepath.Abs(link)
-
-			if absTarget == absLink {
-				fmt.Printf("%-40s  ->  %s\n", "Status ok: "+homePath, link)
-			} else {
-				fmt.Printf("Wrong target: %s -> %s (expected %s)\n", homePath, link, dotPath)
+			if entry.Rendered {
+				fmt.Printf("%-40s  ->  %s\n", "Not rendered: "+entry.Target, entry.Source)
+				continue
 			}
-
-			return nil
-		})
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(statusCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// statusCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// statusCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+			fmt.Printf("%-40s  ->  %s\n", "Missing symlink: "+entry.Target, entry.Source)
+		case dots.StateNotSymlink:
+			fmt.Printf("%-40s  ->  %s\n", "Not a symlink or unreadable: "+entry.Target, "")
+		case dots.StateWrongTarget:
+			fmt.Printf("Wrong target: %s -> %s (expected %s)\n", entry.Target, entry.Link, entry.Source)
+		case dots.StateStale:
+			if entry.Mode != "" {
+				fmt.Printf("Stale: %s (run 'dots link' to refresh it from %s)\n", entry.Target, entry.Source)
+				continue
+			}
+			fmt.Printf("Stale: %s (run 'dots apply' to refresh it from %s)\n", entry.Target, entry.Source)
+		case dots.StateModified:
+			fmt.Printf("Modified: %s (run 'dots capture' to keep the changes in %s)\n", entry.Target, entry.Source)
+		case dots.StateOrphaned:
+			fmt.Printf("Orphaned: %s (%s no longer exists)\n", entry.Target, entry.Source)
+		}
+	}
 }
@@ -0,0 +1,362 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AddOptions configure Add
+type AddOptions struct {
+	// Encrypt stores the file encrypted and leaves the original in place,
+	// readable only by its owner
+	Encrypt bool
+	// Mode keeps the original as a symlink, the default, a copy or a hard link
+	Mode DeployMode
+	// Relative makes the symlink hold a path relative to its own directory
+	Relative bool
+}
+
+// AddResult is the outcome of Add
+type AddResult struct {
+	Dotfile  Dotfile  // the tracked file, its target is the original path
+	Excluded []string // paths of an added directory left in place, see .dotsignore
+}
+
+// Add moves the file or directory at path into the dots directory and puts
+// a symlink in its place, mirroring its path relative to home. A directory
+// holding paths excluded by .dotsignore is added unfolded, file by file. The
+// pre-add and post-add hooks run around it.
+func (m *Manager) Add(filePath string, opts AddOptions) (*AddResult, error) {
+	// Resolve to absolute path
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve path: %w", err)
+	}
+
+	if err := m.runHooks(HookPreAdd, []string{absPath}); err != nil {
+		return nil, err
+	}
+	result, err := m.add(absPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	m.runHooks(HookPostAdd, []string{absPath})
+	return result, nil
+}
+
+func (m *Manager) add(absPath string, opts AddOptions) (*AddResult, error) {
+	mode := opts.Mode
+	if mode != "" && mode != ModeSymlink && opts.Encrypt {
+		return nil, fmt.Errorf("a mode cannot be combined with encryption")
+	}
+	if mode == "" && !opts.Encrypt {
+		mode = ModeSymlink
+	}
+	home, dotsDir := m.home, m.dir
+
+	// Check if source file/directory exists
+	srcInfo, err := os.Lstat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("source does not exist: %s", absPath)
+	}
+
+	// Check if it's already a symlink
+	if srcInfo.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("source is already a symlink: %s", absPath)
+	}
+
+	// Check if file is already inside dots directory (prevent recursive symlinks)
+	if strings.HasPrefix(absPath, dotsDir+string(filepath.Separator)) || absPath == dotsDir {
+		return nil, fmt.Errorf("cannot add files from within dots directory: %s", absPath)
+	}
+
+	// check base name
+	baseName := filepath.Base(absPath)
+
+	// preserving structure of sub-dirs
+	relPath, err := filepath.Rel(home, absPath)
+	var dotsPath string
+
+	if err == nil && !filepath.IsAbs(relPath) && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		// preserve structure
+		dotsPath = filepath.Join(dotsDir, relPath)
+	} else {
+		// file outside home dir, preserve base name
+		dotsPath = filepath.Join(dotsDir, baseName)
+	}
+
+	if opts.Encrypt {
+		dotsPath += encryptedExt
+	}
+	result := &AddResult{Dotfile: Dotfile{Source: dotsPath, Target: absPath, Mode: mode}}
+
+	// Check if destination already exists
+	if _, err := os.Lstat(dotsPath); err == nil {
+		return nil, fmt.Errorf("dotfile %w: %s", ErrExists, dotsPath)
+	}
+
+	// Excluded paths are never tracked, a directory holding some is only
+	// added in part
+	ignore, err := loadIgnore(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+	dotsRel, _ := filepath.Rel(dotsDir, dotsPath)
+	if ignore.Excludes(dotsRel, srcInfo.IsDir()) {
+		return nil, fmt.Errorf("%s is %w", absPath, ErrExcluded)
+	}
+	if srcInfo.IsDir() && mode != ModeCopy && mode != ModeHardlink {
+		excluded, err := ignore.excludedBelow(absPath, dotsRel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", absPath, err)
+		}
+		if len(excluded) > 0 {
+			if err := m.addUnfolded(absPath, dotsPath, dotsRel, ignore, opts.Relative); err != nil {
+				return nil, err
+			}
+			result.Excluded = excluded
+			return result, nil
+		}
+	}
+
+	if opts.Encrypt || mode == ModeCopy || mode == ModeHardlink {
+		if opts.Encrypt {
+			err = m.addEncrypted(absPath, dotsPath, srcInfo)
+		} else {
+			err = m.addCopied(result.Dotfile, srcInfo)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// The original is replaced by a link, keep a copy in the backup store
+	if _, err := m.saveBackup(absPath, "add"); err != nil {
+		return nil, err
+	}
+
+	tx, err := m.beginTransaction("add " + absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.run(func() error {
+		// Create parent directory if needed
+		if err := tx.mkdirAll(filepath.Dir(dotsPath)); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+
+		// Copy file or directory to dots directory
+		if srcInfo.IsDir() {
+			if err := tx.copy(absPath, dotsPath); err != nil {
+				return fmt.Errorf("failed to copy directory: %w", err)
+			}
+			m.logf("Copied directory: %s -> %s", absPath, dotsPath)
+		} else {
+			if err := tx.copy(absPath, dotsPath); err != nil {
+				return fmt.Errorf("failed to copy file: %w", err)
+			}
+			m.logf("Copied file: %s -> %s", absPath, dotsPath)
+		}
+
+		// Move the original aside, it is only deleted once the link is in place
+		if err := tx.remove(absPath); err != nil {
+			return fmt.Errorf("failed to remove original: %w", err)
+		}
+
+		// creating symlink
+		if err := tx.symlink(linkContent(dotsPath, absPath, opts.Relative), absPath); err != nil {
+			return fmt.Errorf("failed to create symlink: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Directories are added folded, one link for the whole tree
+	if srcInfo.IsDir() {
+		if err := m.setFolded(dotsRel, true); err != nil {
+			return nil, err
+		}
+	}
+
+	m.logf("Created symlink: %s -> %s", absPath, dotsPath)
+	return result, nil
+}
+
+// addUnfolded adds a directory holding paths excluded by .dotsignore. The
+// rest is copied to the dots directory and linked file by file, so the
+// directory stays unfolded and the excluded paths stay where they are.
+func (m *Manager) addUnfolded(absPath, dotsPath, relPath string, ignore IgnoreList, relative bool) error {
+	skip := func(rel string, isDir bool) bool {
+		return ignore.Excludes(filepath.Join(relPath, rel), isDir)
+	}
+
+	var files []string
+	err := filepath.WalkDir(absPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == absPath {
+			return nil
+		}
+		rel, _ := filepath.Rel(absPath, path)
+		if skip(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", absPath, err)
+	}
+
+	// Only files that now have an identical copy in the dots directory are
+	// replaced, so unlike a folded add there is nothing to back up
+	tx, err := m.beginTransaction("add " + absPath)
+	if err != nil {
+		return err
+	}
+
+	err = tx.run(func() error {
+		if err := tx.mkdirAll(filepath.Dir(dotsPath)); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := tx.copyExcept(absPath, dotsPath, skip); err != nil {
+			return fmt.Errorf("failed to copy directory: %w", err)
+		}
+		m.logf("Copied directory: %s -> %s", absPath, dotsPath)
+
+		for _, rel := range files {
+			original := filepath.Join(absPath, rel)
+			if err := tx.remove(original); err != nil {
+				return fmt.Errorf("failed to remove original: %w", err)
+			}
+			if err := tx.symlink(linkContent(filepath.Join(dotsPath, rel), original, relative), original); err != nil {
+				return fmt.Errorf("failed to create symlink: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logf("Linked %d file(s) in %s", len(files), absPath)
+	return nil
+}
+
+// addCopied stores a copy of the file in the dots directory. The original
+// stays in place as a copy or is replaced by a hard link to it.
+func (m *Manager) addCopied(df Dotfile, srcInfo os.FileInfo) error {
+	if !srcInfo.Mode().IsRegular() {
+		return fmt.Errorf("%s mode only supports files: %s", df.Mode, df.Target)
+	}
+
+	if df.Mode == ModeHardlink {
+		// The original is replaced by a link, keep a copy in the backup store
+		if _, err := m.saveBackup(df.Target, "add"); err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.beginTransaction("add " + df.Target)
+	if err != nil {
+		return err
+	}
+
+	err = tx.run(func() error {
+		if err := tx.mkdirAll(filepath.Dir(df.Source)); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := tx.copy(df.Target, df.Source); err != nil {
+			return fmt.Errorf("failed to copy file: %w", err)
+		}
+		m.logf("Copied file: %s -> %s", df.Target, df.Source)
+
+		if df.Mode == ModeHardlink {
+			if err := tx.remove(df.Target); err != nil {
+				return fmt.Errorf("failed to remove original: %w", err)
+			}
+			if err := tx.hardlink(df.Source, df.Target); err != nil {
+				return fmt.Errorf("failed to create hard link: %w", err)
+			}
+			m.logf("Created hard link: %s -> %s", df.Target, df.Source)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !m.opts.DryRun {
+		hash, err := hashFile(df.Source)
+		if err != nil {
+			return err
+		}
+		if err := m.recordDeployment(df, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEncrypted stores an encrypted copy of the file in the dots directory.
+// The original is not replaced by a link, it is the decrypted copy.
+func (m *Manager) addEncrypted(absPath, dotsPath string, srcInfo os.FileInfo) error {
+	if !srcInfo.Mode().IsRegular() {
+		return fmt.Errorf("only regular files can be encrypted: %s", absPath)
+	}
+
+	plaintext, err := os.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", absPath, err)
+	}
+
+	passphrase, err := m.newPassphrase()
+	if err != nil {
+		return err
+	}
+	sealed, err := encryptBytes(plaintext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	tx, err := m.beginTransaction("add " + absPath)
+	if err != nil {
+		return err
+	}
+
+	err = tx.run(func() error {
+		if err := tx.mkdirAll(filepath.Dir(dotsPath)); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := tx.writeFile(dotsPath, sealed, 0o600); err != nil {
+			return fmt.Errorf("failed to write encrypted file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.logf("Encrypted file: %s -> %s", absPath, dotsPath)
+
+	// The plaintext copy should only be readable by its owner
+	if srcInfo.Mode().Perm() != 0o600 {
+		if m.opts.DryRun {
+			m.planf("chmod 600 %s", absPath)
+		} else if err := os.Chmod(absPath, 0o600); err != nil {
+			return fmt.Errorf("failed to restrict permissions: %w", err)
+		}
+	}
+	return nil
+}
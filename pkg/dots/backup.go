@@ -0,0 +1,415 @@
+package dots
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The backup store keeps a copy of everything dots is about to overwrite or
+// delete. File contents are stored once per content hash in objects/, every
+// backup is a record in records/ listing the files it is made of.
+const (
+	backupsDir = "backups"
+	objectsDir = "objects"
+	recordsDir = "records"
+)
+
+// Retention defaults, overridden by DOTS_BACKUP_KEEP and DOTS_BACKUP_DAYS
+const (
+	defaultBackupKeep = 50
+	defaultBackupDays = 0
+)
+
+// Backup is a single saved path, a file, a symlink or a whole directory
+type Backup struct {
+	ID      string       `json:"id"`
+	Path    string       `json:"path"`
+	Reason  string       `json:"reason"`
+	Created time.Time    `json:"created"`
+	Files   []BackupFile `json:"files"`
+}
+
+// BackupFile is one entry of a backup. Path is relative to the backed up
+// path, "." being the path itself.
+type BackupFile struct {
+	Path string      `json:"path"`
+	Mode fs.FileMode `json:"mode"`
+	Hash string      `json:"hash,omitempty"`
+	Link string      `json:"link,omitempty"`
+	Size int64       `json:"size,omitempty"`
+}
+
+// Size returns the total size of the files in the backup
+func (b *Backup) Size() int64 {
+	var total int64
+	for _, file := range b.Files {
+		total += file.Size
+	}
+	return total
+}
+
+func (m *Manager) backupRoot() string {
+	return filepath.Join(m.stateDir, backupsDir)
+}
+
+// saveBackup copies path into the backup store before it is overwritten or
+// deleted and returns the ID of the new backup. In dry-run mode nothing is
+// saved and the ID is empty.
+func (m *Manager) saveBackup(path, reason string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if m.opts.DryRun {
+		m.planf("back up %s", absPath)
+		return "", nil
+	}
+
+	root := m.backupRoot()
+	for _, dir := range []string{objectsDir, recordsDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o700); err != nil {
+			return "", fmt.Errorf("failed to create backup store: %w", err)
+		}
+	}
+
+	created := time.Now()
+	sum := sha256.Sum256([]byte(absPath + created.Format(time.RFC3339Nano)))
+	b := &Backup{
+		ID:      hex.EncodeToString(sum[:4]),
+		Path:    absPath,
+		Reason:  reason,
+		Created: created,
+	}
+
+	err = filepath.WalkDir(absPath, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(absPath, current)
+		file := BackupFile{Path: relPath, Mode: info.Mode()}
+
+		switch {
+		case info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			if file.Link, err = os.Readlink(current); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if file.Hash, err = storeObject(root, current); err != nil {
+				return err
+			}
+			file.Size = info.Size()
+		default:
+			// Sockets, devices and pipes cannot be restored from a copy
+			return nil
+		}
+		b.Files = append(b.Files, file)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", absPath, err)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(root, recordsDir, b.ID+".json"), data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write backup record: %w", err)
+	}
+
+	if _, err := m.PruneBackups(); err != nil {
+		return b.ID, err
+	}
+	return b.ID, nil
+}
+
+// storeObject copies a file into the object store under its content hash.
+// Content that is already stored is not written again.
+func storeObject(root, path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(root, objectsDir), "tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), src); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	object := objectPath(root, sum)
+	if _, err := os.Stat(object); err == nil {
+		return sum, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(object), 0o700); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(tmp.Name(), object)
+}
+
+func objectPath(root, hash string) string {
+	return filepath.Join(root, objectsDir, hash[:2], hash[2:])
+}
+
+// Backups returns every backup in the store, oldest first
+func (m *Manager) Backups() ([]*Backup, error) {
+	root := m.backupRoot()
+	entries, err := os.ReadDir(filepath.Join(root, recordsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup store: %w", err)
+	}
+
+	var backups []*Backup
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, recordsDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		b := &Backup{}
+		if err := json.Unmarshal(data, b); err != nil {
+			return nil, fmt.Errorf("corrupt backup record %s: %w", entry.Name(), err)
+		}
+		backups = append(backups, b)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.Before(backups[j].Created)
+	})
+	return backups, nil
+}
+
+// FindBackup looks a backup up by a unique prefix of its ID, or returns the
+// latest backup of a path
+func (m *Manager) FindBackup(arg string) (*Backup, error) {
+	backups, err := m.Backups()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Backup
+	for _, b := range backups {
+		if strings.HasPrefix(b.ID, arg) {
+			matches = append(matches, b)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+	default:
+		return nil, fmt.Errorf("backup ID '%s' is ambiguous, use more characters", arg)
+	}
+
+	absPath, err := filepath.Abs(expandHome(arg, m.home))
+	if err != nil {
+		return nil, err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		if backups[i].Path == absPath {
+			return backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no backup found for '%s'", arg)
+}
+
+// RestoreBackup puts a backup back at its original path. Whatever is there
+// now is backed up itself first, unless it is just a symlink.
+func (m *Manager) RestoreBackup(b *Backup) error {
+	root := m.backupRoot()
+
+	info, err := os.Lstat(b.Path)
+	existing := err == nil
+	// Links are what dots creates, they are not worth keeping
+	keepExisting := existing && info.Mode()&fs.ModeSymlink == 0
+
+	if m.opts.DryRun {
+		if keepExisting {
+			m.planf("back up %s", b.Path)
+		}
+		m.planf("restore backup %s to %s", b.ID, b.Path)
+		return nil
+	}
+
+	if err := m.mkdirAll(filepath.Dir(b.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	// Rebuild the backup next to its destination, then swap it in
+	tmpDir, err := os.MkdirTemp(filepath.Dir(b.Path), ".dots-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	staged := filepath.Join(tmpDir, filepath.Base(b.Path))
+	if err := materializeBackup(root, b, staged); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", b.Path, err)
+	}
+
+	// Only now, saving a new backup may prune the one being restored
+	if keepExisting {
+		id, err := m.saveBackup(b.Path, "restore")
+		if err != nil {
+			return err
+		}
+		m.logf("Backed up current %s as %s", b.Path, id)
+	}
+
+	tx, err := m.beginTransaction("restore " + b.Path)
+	if err != nil {
+		return err
+	}
+	return tx.run(func() error {
+		if existing {
+			if err := tx.remove(b.Path); err != nil {
+				return fmt.Errorf("failed to move %s aside: %w", b.Path, err)
+			}
+		}
+		return tx.rename(staged, b.Path)
+	})
+}
+
+// materializeBackup writes the files of a backup below dest
+func materializeBackup(root string, b *Backup, dest string) error {
+	var dirs []BackupFile
+	for _, file := range b.Files {
+		target := filepath.Join(dest, file.Path)
+
+		switch {
+		case file.Mode.IsDir():
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, file)
+		case file.Mode&fs.ModeSymlink != 0:
+			if err := os.Symlink(file.Link, target); err != nil {
+				return err
+			}
+		default:
+			data, err := os.ReadFile(objectPath(root, file.Hash))
+			if err != nil {
+				return fmt.Errorf("missing content for %s: %w", file.Path, err)
+			}
+			if err := os.WriteFile(target, data, file.Mode.Perm()); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, file.Mode.Perm()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Directories get their permissions last, they may not be writable
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(filepath.Join(dest, dirs[i].Path), dirs[i].Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupRetention reads how many backups to keep and for how many days.
+// Zero means no limit.
+func backupRetention() (keep, days int, err error) {
+	keep, days = defaultBackupKeep, defaultBackupDays
+	if value := os.Getenv("DOTS_BACKUP_KEEP"); value != "" {
+		if keep, err = strconv.Atoi(value); err != nil || keep < 0 {
+			return 0, 0, fmt.Errorf("invalid DOTS_BACKUP_KEEP '%s', want a number of backups", value)
+		}
+	}
+	if value := os.Getenv("DOTS_BACKUP_DAYS"); value != "" {
+		if days, err = strconv.Atoi(value); err != nil || days < 0 {
+			return 0, 0, fmt.Errorf("invalid DOTS_BACKUP_DAYS '%s', want a number of days", value)
+		}
+	}
+	return keep, days, nil
+}
+
+// PruneBackups deletes the backups that fall outside the retention limits
+// and the contents no remaining backup refers to. It returns the number of
+// deleted backups.
+func (m *Manager) PruneBackups() (int, error) {
+	keep, days, err := backupRetention()
+	if err != nil {
+		return 0, err
+	}
+
+	backups, err := m.Backups()
+	if err != nil {
+		return 0, err
+	}
+	root := m.backupRoot()
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	referenced := map[string]bool{}
+	pruned := 0
+	for i, b := range backups {
+		tooMany := keep > 0 && i < len(backups)-keep
+		tooOld := days > 0 && b.Created.Before(cutoff)
+		if !tooMany && !tooOld {
+			for _, file := range b.Files {
+				referenced[file.Hash] = true
+			}
+			continue
+		}
+
+		if m.opts.DryRun {
+			m.planf("delete backup %s of %s", b.ID, b.Path)
+		} else if err := os.Remove(filepath.Join(root, recordsDir, b.ID+".json")); err != nil {
+			return pruned, fmt.Errorf("failed to delete backup %s: %w", b.ID, err)
+		}
+		pruned++
+	}
+	if pruned == 0 || m.opts.DryRun {
+		return pruned, nil
+	}
+
+	// Drop contents that only the deleted backups referred to
+	objects := filepath.Join(root, objectsDir)
+	err = filepath.WalkDir(objects, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(objects, current)
+		if !referenced[strings.ReplaceAll(relPath, string(filepath.Separator), "")] {
+			return os.Remove(current)
+		}
+		return nil
+	})
+	if err != nil {
+		return pruned, fmt.Errorf("failed to clean up backup contents: %w", err)
+	}
+	return pruned, nil
+}
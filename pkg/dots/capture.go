@@ -0,0 +1,95 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+)
+
+// Capture copies the targets of the named dotfiles deployed as copies or
+// hard links back over their tracked files, or of every such dotfile that was
+// modified when no names are given. The previous versions go to the backup
+// store. It returns the dotfiles that changed.
+func (m *Manager) Capture(names []string) ([]Dotfile, error) {
+	if err := m.checkDir(); err != nil {
+		return nil, err
+	}
+
+	selected, err := m.selectDotfiles(names, len(names) == 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveModes(selected, ""); err != nil {
+		return nil, err
+	}
+
+	var captured []Dotfile
+	for _, df := range selected {
+		if !df.IsCopied() || df.IsTemplate() || df.IsEncrypted() {
+			if len(names) > 0 {
+				m.log.Warnf("%s is not a copy, edits to it already land in the repo", df.Target)
+			}
+			continue
+		}
+
+		changed, err := m.captureDotfile(df)
+		if err != nil {
+			return captured, fmt.Errorf("%s: %w", df.Target, err)
+		}
+		if changed {
+			captured = append(captured, df)
+		}
+	}
+	return captured, nil
+}
+
+// captureDotfile copies the target of df over its source when they differ
+func (m *Manager) captureDotfile(df Dotfile) (bool, error) {
+	info, err := os.Lstat(df.Target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("target is not a regular file")
+	}
+
+	targetHash, err := hashFile(df.Target)
+	if err != nil {
+		return false, err
+	}
+	sourceHash, err := hashFile(df.Source)
+	if err != nil {
+		return false, err
+	}
+	if targetHash == sourceHash {
+		return false, nil
+	}
+
+	deployments, err := m.loadDeployments()
+	if err != nil {
+		return false, err
+	}
+	if record, ok := deployments[df.Target]; ok && record.Hash != sourceHash {
+		m.log.Warnf("%s also changed in the repo since it was deployed, that version goes to the backup store", df.Source)
+	}
+
+	if _, err := m.saveBackup(df.Source, "capture"); err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(df.Target)
+	if err != nil {
+		return false, err
+	}
+	if _, err := m.writeDeployed(df.Source, content, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	m.logf("Captured %s -> %s", df.Target, df.Source)
+
+	// Writing the source replaced its inode, link the target to it again
+	if df.Mode == ModeHardlink {
+		return true, m.deployCopy(df)
+	}
+	return true, m.recordDeployment(df, targetHash)
+}
@@ -0,0 +1,137 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConflictStrategy decides what happens when something is already at the
+// target of a dotfile
+type ConflictStrategy string
+
+const (
+	ConflictSkip      ConflictStrategy = "skip"      // leave the target alone
+	ConflictBackup    ConflictStrategy = "backup"    // move the target to the backup store
+	ConflictOverwrite ConflictStrategy = "overwrite" // delete the target
+	ConflictAdopt     ConflictStrategy = "adopt"     // replace the tracked copy with the target
+	ConflictDiff      ConflictStrategy = "diff"      // let LinkOptions.Ask decide, after showing the differences
+)
+
+// ParseConflictStrategy parses skip, backup, overwrite, adopt or diff
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(value); strategy {
+	case ConflictSkip, ConflictBackup, ConflictOverwrite, ConflictAdopt, ConflictDiff:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid conflict strategy '%s' (want skip, backup, overwrite, adopt or diff)", value)
+}
+
+// resolveConflict clears the target of df according to the conflict
+// strategy and reports whether the dotfile should be deployed afterwards
+func (m *Manager) resolveConflict(df Dotfile, opts LinkOptions) (bool, error) {
+	strategy := opts.OnConflict
+	if strategy == ConflictDiff {
+		strategy = ConflictSkip
+		if opts.Ask != nil {
+			strategy = opts.Ask(df)
+		}
+	}
+
+	switch strategy {
+	case ConflictBackup:
+		id, err := m.backupTarget(df.Target, "link")
+		if err != nil {
+			return false, err
+		}
+		m.logf("Backed up %s (restore with 'dots restore %s')", df.Target, id)
+		return true, nil
+	case ConflictOverwrite:
+		if _, err := m.backupTarget(df.Target, "overwrite"); err != nil {
+			return false, err
+		}
+		m.logf("Removed %s", df.Target)
+		return true, nil
+	case ConflictAdopt:
+		return true, m.adoptTarget(df)
+	}
+	return false, nil
+}
+
+// backupTarget saves a conflicting target in the backup store and removes
+// it. It returns the ID of the backup.
+func (m *Manager) backupTarget(target, reason string) (string, error) {
+	id, err := m.saveBackup(target, reason)
+	if err != nil {
+		return "", err
+	}
+	if err := m.removeAll(target); err != nil {
+		return "", fmt.Errorf("failed to remove %s: %w", target, err)
+	}
+	return id, nil
+}
+
+// adoptTarget replaces the tracked copy of df with what is at its target and
+// removes the target, so it can be deployed from the repo again. A target
+// that is a symlink to somewhere else has the content it points to adopted,
+// and only the link is removed. Encrypted dotfiles are sealed again,
+// templates cannot be adopted.
+func (m *Manager) adoptTarget(df Dotfile) error {
+	if df.IsTemplate() {
+		return fmt.Errorf("cannot adopt %s into template %s, edit the template instead", df.Target, df.Source)
+	}
+	content, err := filepath.EvalSymlinks(df.Target)
+	if err != nil {
+		return fmt.Errorf("nothing to adopt at %s: %w", df.Target, err)
+	}
+	if source, err := filepath.EvalSymlinks(df.Source); err == nil && (isWithin(content, source) || isWithin(source, content)) {
+		return fmt.Errorf("cannot adopt %s, it links to %s which overlaps the tracked copy", df.Target, content)
+	}
+
+	var sealed []byte
+	if df.IsEncrypted() {
+		plaintext, err := os.ReadFile(df.Target)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", df.Target, err)
+		}
+		passphrase, err := m.passphrase(false)
+		if err != nil {
+			return err
+		}
+		if sealed, err = encryptBytes(plaintext, passphrase); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", df.Target, err)
+		}
+	}
+
+	// The tracked copy is replaced, keep it in the backup store
+	if _, err := m.saveBackup(df.Source, "adopt"); err != nil {
+		return err
+	}
+
+	tx, err := m.beginTransaction("adopt " + df.Target)
+	if err != nil {
+		return err
+	}
+	err = tx.run(func() error {
+		if err := tx.remove(df.Source); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", df.Source, err)
+		}
+		if sealed != nil {
+			if err := tx.writeFile(df.Source, sealed, 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", df.Source, err)
+			}
+		} else if err := tx.copy(content, df.Source); err != nil {
+			return fmt.Errorf("failed to copy %s into the repo: %w", df.Target, err)
+		}
+		if err := tx.remove(df.Target); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", df.Target, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logf("Adopted %s -> %s", df.Target, df.Source)
+	return nil
+}
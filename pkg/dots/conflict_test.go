@@ -0,0 +1,61 @@
+package dots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptSymlinkedTarget(t *testing.T) {
+	m := newTestManager(t, Options{})
+	df := Dotfile{
+		Source: filepath.Join(m.dir, ".gitconfig"),
+		Target: filepath.Join(m.home, ".gitconfig"),
+	}
+	elsewhere := filepath.Join(m.home, "shared", "gitconfig")
+	writeTestFile(t, df.Source, "tracked\n")
+	writeTestFile(t, elsewhere, "adopted\n")
+	if err := os.Symlink(elsewhere, df.Target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.adoptTarget(df); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(df.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("%s is a %v, want the content the target linked to", df.Source, info.Mode().Type())
+	}
+	if got := readTestFile(t, df.Source); got != "adopted\n" {
+		t.Errorf("%s holds %q, want the adopted content", df.Source, got)
+	}
+	if _, err := os.Lstat(df.Target); !os.IsNotExist(err) {
+		t.Errorf("the link at %s was not removed: %v", df.Target, err)
+	}
+	if got := readTestFile(t, elsewhere); got != "adopted\n" {
+		t.Errorf("the file the target linked to was changed to %q", got)
+	}
+}
+
+func TestAdoptRefusesLinkIntoSource(t *testing.T) {
+	m := newTestManager(t, Options{})
+	df := Dotfile{
+		Source: filepath.Join(m.dir, ".config", "nvim"),
+		Target: filepath.Join(m.home, ".config", "nvim"),
+	}
+	writeTestFile(t, filepath.Join(df.Source, "init.lua"), "tracked\n")
+	if err := os.Symlink(filepath.Join(df.Source, "init.lua"), df.Target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.adoptTarget(df); err == nil {
+		t.Fatal("adopting a link into the tracked copy succeeded")
+	}
+	if got := readTestFile(t, filepath.Join(df.Source, "init.lua")); got != "tracked\n" {
+		t.Errorf("the tracked copy was changed to %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package dots
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// skipFunc reports whether a path inside a copied directory, relative to
+// it, is left out of the copy
+type skipFunc func(relPath string, isDir bool) bool
+
+// copyMode keeps the permission bits of a file together with setuid, setgid
+// and sticky
+const copyMode = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// copyTree copies a file, symlink or directory to dst. Symlinks are copied as
+// links rather than followed, special files such as sockets and FIFOs are
+// left out of directories and refused on their own.
+func (m *Manager) copyTree(src, dst string, skip skipFunc) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		return m.copyDir(src, dst, skip)
+	case mode&os.ModeSymlink != 0:
+		return copySymlink(src, dst)
+	case mode.IsRegular():
+		return copyFile(src, dst)
+	}
+	return fmt.Errorf("cannot copy %s, it is a %s", src, specialKind(info.Mode()))
+}
+
+// copyFile copies a regular file atomically, keeping its mode and
+// modification time
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot copy %s, it is a %s", src, specialKind(info.Mode()))
+	}
+	return writeAtomic(dst, in, info.Mode()&copyMode, info.ModTime())
+}
+
+// copySymlink creates a symlink at dst with the same content as the one at
+// src. Relative links keep pointing inside a copied tree.
+func copySymlink(src, dst string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(link, dst)
+}
+
+// copyDir recursively copies a directory, leaving out what skip matches.
+// Special files are reported and left out.
+func (m *Manager) copyDir(src, dst string, skip skipFunc) error {
+	type copiedDir struct {
+		path string
+		info os.FileInfo
+	}
+	var dirs []copiedDir
+
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if path != src && skip != nil && skip(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			// Writable until its content is in place, see below
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, copiedDir{target, info})
+		case mode&os.ModeSymlink != 0:
+			return copySymlink(path, target)
+		case mode.IsRegular():
+			return copyFile(path, target)
+		default:
+			m.log.Warnf("Skipped %s, it is a %s", path, specialKind(mode))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Modes and times of directories are set last, innermost first, as
+	// writing their content changes both
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		if err := os.Chmod(dir.path, dir.info.Mode()&copyMode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dir.path, dir.info.ModTime(), dir.info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAtomic writes the content of r to a temporary file next to path,
+// syncs it to disk and renames it over path, so path never holds half a
+// file. A zero mtime leaves the modification time at now.
+func writeAtomic(path string, r io.Reader, perm os.FileMode, mtime time.Time) error {
+	tmp := path + ".dots-write"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		if _, err := io.Copy(f, r); err != nil {
+			return err
+		}
+		// Chmod rather than the open mode, which the umask would narrow
+		if err := f.Chmod(perm); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		return f.Close()
+	}()
+	if err == nil && !mtime.IsZero() {
+		err = os.Chtimes(tmp, mtime, mtime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir flushes a directory to disk so a rename in it survives a crash.
+// Not every filesystem supports it, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// specialKind names the type of a file that is not a regular file,
+// directory or symlink
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
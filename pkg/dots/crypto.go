@@ -0,0 +1,228 @@
+package dots
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedExt marks dotfiles stored encrypted in the dots directory. They
+// are decrypted to their target instead of linked.
+const encryptedExt = ".enc"
+
+// Encrypted files start with a magic header followed by the scrypt salt, the
+// GCM nonce and the sealed content. The header is authenticated as well.
+var encryptedMagic = []byte("DOTSENC1")
+
+const (
+	saltSize = 16
+	keySize  = 32
+
+	// scrypt cost parameters, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// passphrase returns the passphrase from DOTS_PASSPHRASE or asks
+// Options.Passphrase for it, once per Manager. With confirm set it has to be
+// typed twice.
+func (m *Manager) passphrase(confirm bool) ([]byte, error) {
+	if m.secret != nil {
+		return m.secret, nil
+	}
+
+	if env := os.Getenv("DOTS_PASSPHRASE"); env != "" {
+		m.secret = []byte(env)
+		return m.secret, nil
+	}
+
+	if m.opts.Passphrase == nil {
+		return nil, fmt.Errorf("%w: set DOTS_PASSPHRASE", ErrNoPassphrase)
+	}
+	passphrase, err := m.opts.Passphrase(confirm)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	m.secret = passphrase
+	return m.secret, nil
+}
+
+// newPassphrase returns the passphrase to encrypt a new dotfile with. It has
+// to open the dotfiles encrypted already, only while there are none it is
+// confirmed by typing it twice instead.
+func (m *Manager) newPassphrase() ([]byte, error) {
+	if m.secret != nil {
+		return m.secret, nil
+	}
+	sample, err := m.encryptedSample()
+	if err != nil {
+		return nil, err
+	}
+	if sample == "" {
+		return m.passphrase(true)
+	}
+	if _, err := m.decryptFile(sample); err != nil {
+		// Ask again next time rather than keep a wrong passphrase
+		m.secret = nil
+		return nil, fmt.Errorf("the passphrase does not match the encrypted dotfiles: %w", err)
+	}
+	return m.secret, nil
+}
+
+// encryptedSample returns an encrypted file of the dots directory, or the
+// empty string when there is none
+func (m *Manager) encryptedSample() (string, error) {
+	sample := ""
+	err := filepath.WalkDir(m.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == m.dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if entry.Type().IsRegular() && strings.HasSuffix(path, encryptedExt) {
+			sample = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look for encrypted files: %w", err)
+	}
+	return sample, nil
+}
+
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptBytes seals plaintext with a key derived from the passphrase
+func encryptBytes(plaintext, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+saltSize+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// decryptBytes opens data produced by encryptBytes
+func decryptBytes(data, passphrase []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("not a dots encrypted file")
+	}
+	data = data[len(encryptedMagic):]
+
+	if len(data) < saltSize {
+		return nil, ErrBadPassphrase
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return plaintext, nil
+}
+
+// decryptFile reads and decrypts an encrypted dotfile
+func (m *Manager) decryptFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := m.passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decryptBytes(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+// deployEncrypted decrypts the dotfile to its target, readable only by the
+// owner. It reports whether the target changed.
+func (m *Manager) deployEncrypted(df Dotfile) (bool, error) {
+	plaintext, err := m.decryptFile(df.Source)
+	if err != nil {
+		return false, err
+	}
+	return m.writeDeployed(df.Target, plaintext, 0o600)
+}
+
+// Decrypt returns the plaintext of an encrypted dotfile
+func (m *Manager) Decrypt(df Dotfile) ([]byte, error) {
+	return m.decryptFile(df.Source)
+}
+
+// Encrypt replaces the content of an encrypted dotfile with plaintext,
+// sealed with the passphrase
+func (m *Manager) Encrypt(df Dotfile, plaintext []byte) error {
+	passphrase, err := m.passphrase(false)
+	if err != nil {
+		return err
+	}
+	sealed, err := encryptBytes(plaintext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	info, err := os.Stat(df.Source)
+	if err != nil {
+		return err
+	}
+	_, err = m.writeDeployed(df.Source, sealed, info.Mode().Perm())
+	return err
+}
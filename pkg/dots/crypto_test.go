@@ -0,0 +1,69 @@
+package dots
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// passphraseManager returns a Manager sharing the directories of m whose
+// passphrase prompt answers passphrase and records whether it asked for a
+// confirmation
+func passphraseManager(t *testing.T, m *Manager, passphrase string, confirms *[]bool) *Manager {
+	t.Helper()
+	other, err := New(Options{
+		Home:     m.home,
+		Dir:      m.dir,
+		StateDir: m.stateDir,
+		Passphrase: func(confirm bool) ([]byte, error) {
+			*confirms = append(*confirms, confirm)
+			return []byte(passphrase), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return other
+}
+
+func TestAddEncryptedChecksPassphrase(t *testing.T) {
+	t.Setenv("DOTS_PASSPHRASE", "")
+	m := newTestManager(t, Options{})
+	first := filepath.Join(m.home, ".netrc")
+	second := filepath.Join(m.home, ".pgpass")
+	writeTestFile(t, first, "machine example.com\n")
+	writeTestFile(t, second, "localhost:5432:*:me:secret\n")
+
+	// The first encrypted file sets the passphrase, typed twice
+	var confirms []bool
+	if _, err := passphraseManager(t, m, "right", &confirms).Add(first, AddOptions{Encrypt: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirms) != 1 || !confirms[0] {
+		t.Errorf("first add asked with confirm %v, want one confirmed prompt", confirms)
+	}
+
+	// Later ones must use the same
+	confirms = nil
+	_, err := passphraseManager(t, m, "wrong", &confirms).Add(second, AddOptions{Encrypt: true})
+	if !errors.Is(err, ErrBadPassphrase) {
+		t.Fatalf("add with another passphrase = %v, want ErrBadPassphrase", err)
+	}
+	if len(confirms) != 1 || confirms[0] {
+		t.Errorf("second add asked with confirm %v, want one unconfirmed prompt", confirms)
+	}
+	if _, err := os.Lstat(filepath.Join(m.dir, ".pgpass"+encryptedExt)); !os.IsNotExist(err) {
+		t.Errorf("%s was encrypted with the wrong passphrase: %v", second, err)
+	}
+
+	confirms = nil
+	right := passphraseManager(t, m, "right", &confirms)
+	if _, err := right.Add(second, AddOptions{Encrypt: true}); err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := right.Decrypt(Dotfile{Source: filepath.Join(m.dir, ".pgpass"+encryptedExt)})
+	if err != nil || string(plaintext) != "localhost:5432:*:me:secret\n" {
+		t.Errorf("Decrypt = %q, %v", plaintext, err)
+	}
+}
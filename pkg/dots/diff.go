@@ -0,0 +1,329 @@
+package dots
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// RemoteDiff compares the current branch with the branch it pulls from, as
+// of the last fetch
+type RemoteDiff struct {
+	Upstream string   // the remote-tracking branch, such as origin/main
+	Base     string   // the merge-base of both branches
+	Incoming []Commit // what a pull would bring in, newest first
+	Outgoing []Commit // what a push would send, newest first
+}
+
+// DiffWorking writes the uncommitted changes in the dots directory to w as a
+// unified diff and returns them. Untracked files are shown as added.
+func (m *Manager) DiffWorking(w io.Writer) ([]Change, error) {
+	if err := m.checkRepo(); err != nil {
+		return nil, err
+	}
+
+	repo, err := m.Repo()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := repo.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	if err := repo.Diff(w, "HEAD", ""); err != nil {
+		return nil, fmt.Errorf("failed to diff: %w", err)
+	}
+	return changes, nil
+}
+
+// DiffRemote fetches origin and compares the current branch with its
+// upstream. In dry-run mode the fetch is only planned and the comparison uses
+// what was fetched before.
+func (m *Manager) DiffRemote() (*RemoteDiff, error) {
+	if err := m.checkRepo(); err != nil {
+		return nil, err
+	}
+
+	repo, err := m.Repo()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.RemoteURL(); err != nil {
+		return nil, fmt.Errorf("%w\nAdd a remote with: cd %s && git remote add origin <url>", ErrNoRemote, m.dir)
+	}
+
+	m.log.Infof("Fetching from remote...")
+	if err := repo.Fetch(); err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+
+	upstream, err := repo.Upstream()
+	if err != nil {
+		return nil, fmt.Errorf("current branch has no upstream, push it with 'git push -u origin <branch>' first: %w", err)
+	}
+
+	result := &RemoteDiff{Upstream: upstream}
+	if result.Base, err = repo.MergeBase("HEAD", upstream); err != nil {
+		return nil, fmt.Errorf("failed to find the merge-base with %s: %w", upstream, err)
+	}
+	if result.Incoming, err = repo.Log("HEAD", upstream); err != nil {
+		return nil, fmt.Errorf("failed to list incoming commits: %w", err)
+	}
+	if result.Outgoing, err = repo.Log(upstream, "HEAD"); err != nil {
+		return nil, fmt.Errorf("failed to list outgoing commits: %w", err)
+	}
+	return result, nil
+}
+
+// DiffRevisions writes the changes between two revisions, such as the Base
+// and Upstream of a RemoteDiff, to w as a unified diff
+func (m *Manager) DiffRevisions(w io.Writer, from, to string) error {
+	repo, err := m.Repo()
+	if err != nil {
+		return err
+	}
+	if err := repo.Diff(w, from, to); err != nil {
+		return fmt.Errorf("failed to diff: %w", err)
+	}
+	return nil
+}
+
+// DiffDeployed writes how the copied, rendered and decrypted dotfiles differ
+// from what is deployed at their targets to w, and returns the ones that
+// differ. Without names every tracked dotfile is compared. Symlinked
+// dotfiles always match their source and are left out.
+func (m *Manager) DiffDeployed(w io.Writer, names []string) ([]Dotfile, error) {
+	dotfiles, err := m.selectDotfiles(names, len(names) == 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveModes(dotfiles, ""); err != nil {
+		return nil, err
+	}
+
+	var differ []Dotfile
+	for _, df := range dotfiles {
+		if !df.IsTemplate() && !df.IsEncrypted() && !df.IsCopied() {
+			continue
+		}
+		patch, err := m.deployedPatch(df)
+		if err != nil {
+			return differ, fmt.Errorf("failed to diff %s: %w", df.Target, err)
+		}
+		if patch == nil {
+			continue
+		}
+		if err := encodePatch(w, patch); err != nil {
+			return differ, err
+		}
+		differ = append(differ, df)
+	}
+	return differ, nil
+}
+
+// Diff writes how the target of df differs from what dots would deploy
+// there to w, as a unified diff
+func (m *Manager) Diff(w io.Writer, df Dotfile) error {
+	patch, err := m.deployedPatch(df)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", df.Target, err)
+	}
+	if patch == nil {
+		return nil
+	}
+	return encodePatch(w, patch)
+}
+
+// deployedPatch compares the target of df with what dots would deploy there.
+// Copied directories are compared file by file. Both sides are named after
+// the target, relative to home, so the diff applies there with patch -p1. It
+// returns nil when everything matches.
+func (m *Manager) deployedPatch(df Dotfile) (*patch, error) {
+	var want []byte
+	var err error
+	switch {
+	case df.IsTemplate():
+		var data *templateData
+		if data, err = m.loadTemplateData(); err != nil {
+			return nil, err
+		}
+		want, err = renderTemplate(df.Source, data)
+	case df.IsEncrypted():
+		want, err = m.decryptFile(df.Source)
+	default:
+		return m.copiedPatch(df.Source, df.Target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := m.deployedFilePatch(df.Target, want)
+	if err != nil || fp == nil {
+		return nil, err
+	}
+	return &patch{files: []fdiff.FilePatch{fp}}, nil
+}
+
+// copiedPatch compares a copied file or directory tree with its source
+func (m *Manager) copiedPatch(source, target string) (*patch, error) {
+	p := &patch{}
+	err := filepath.WalkDir(source, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(source, path)
+		want, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fp, err := m.deployedFilePatch(filepath.Join(target, rel), want)
+		if err != nil || fp == nil {
+			return err
+		}
+		p.files = append(p.files, fp)
+		return nil
+	})
+	if err != nil || len(p.files) == 0 {
+		return nil, err
+	}
+	return p, nil
+}
+
+// deployedFilePatch compares the file at target with the content dots would
+// deploy there. It returns nil when they match.
+func (m *Manager) deployedFilePatch(target string, want []byte) (*filePatch, error) {
+	path := target
+	if isWithin(target, m.home) {
+		path, _ = filepath.Rel(m.home, target)
+	}
+	path = filepath.ToSlash(path)
+
+	have, err := os.ReadFile(target)
+	if os.IsNotExist(err) {
+		return newFilePatch(nil, &patchFile{path: path, content: want}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(have, want) {
+		return nil, nil
+	}
+	return newFilePatch(&patchFile{path: path, content: have}, &patchFile{path: path, content: want}), nil
+}
+
+// encodePatch writes p as a unified diff in the format of git diff
+func encodePatch(w io.Writer, p fdiff.Patch) error {
+	return fdiff.NewUnifiedEncoder(w, fdiff.DefaultContextLines).Encode(p)
+}
+
+// patch implements fdiff.Patch for content that is not in a commit
+type patch struct {
+	files []fdiff.FilePatch
+}
+
+func (p *patch) FilePatches() []fdiff.FilePatch { return p.files }
+func (p *patch) Message() string                { return "" }
+
+// patchFile implements fdiff.File for content read from disk or a tree
+type patchFile struct {
+	path    string
+	content []byte
+	mode    filemode.FileMode
+}
+
+func (f *patchFile) Hash() plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, f.content)
+}
+
+func (f *patchFile) Mode() filemode.FileMode {
+	if f.mode == filemode.Empty {
+		return filemode.Regular
+	}
+	return f.mode
+}
+
+func (f *patchFile) Path() string { return f.path }
+
+// filePatch implements fdiff.FilePatch
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+// newFilePatch compares two versions of a file, from is nil for an added
+// file and to is nil for a deleted one
+func newFilePatch(from, to *patchFile) *filePatch {
+	fp := &filePatch{from: from, to: to}
+	var src, dst []byte
+	if from != nil {
+		src = from.content
+	}
+	if to != nil {
+		dst = to.content
+	}
+	if isBinary(src) || isBinary(dst) {
+		fp.binary = true
+		return fp
+	}
+
+	for _, d := range diff.Do(string(src), string(dst)) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		}
+		fp.chunks = append(fp.chunks, chunk{content: d.Text, op: op})
+	}
+	return fp
+}
+
+func (fp *filePatch) IsBinary() bool { return fp.binary }
+
+func (fp *filePatch) Files() (fdiff.File, fdiff.File) {
+	// A nil *patchFile must become a nil interface
+	var from, to fdiff.File
+	if fp.from != nil {
+		from = fp.from
+	}
+	if fp.to != nil {
+		to = fp.to
+	}
+	return from, to
+}
+
+func (fp *filePatch) Chunks() []fdiff.Chunk { return fp.chunks }
+
+// chunk implements fdiff.Chunk
+type chunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c chunk) Content() string       { return c.content }
+func (c chunk) Type() fdiff.Operation { return c.op }
+
+// isBinary guesses like git does, content with a NUL byte in its first 8000
+// bytes is binary
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
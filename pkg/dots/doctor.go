@@ -0,0 +1,189 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// CheckLevel is how a health check turned out
+type CheckLevel string
+
+const (
+	CheckOK   CheckLevel = "ok"
+	CheckWarn CheckLevel = "warning" // works, but something is off
+	CheckFail CheckLevel = "failure" // needs fixing
+)
+
+// Check is the outcome of a single health check
+type Check struct {
+	Level   CheckLevel `json:"level"`
+	Message string     `json:"message"`
+	Fix     string     `json:"fix,omitempty"` // how to fix a warning or failure
+}
+
+// checks collects the outcome of the health checks
+type checks []Check
+
+func (c *checks) ok(format string, args ...any) {
+	*c = append(*c, Check{Level: CheckOK, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checks) warn(fix, format string, args ...any) {
+	*c = append(*c, Check{Level: CheckWarn, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+func (c *checks) fail(fix, format string, args ...any) {
+	*c = append(*c, Check{Level: CheckFail, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+// Doctor runs every health check and reports how to fix what is wrong: git
+// and the editor are installed, the dots directory is a repository with a
+// remote, tracked links are neither dangling nor leaving the dots directory
+// and no file in it is world-writable or excluded by .gitignore. The checks
+// stop early when the dots directory is missing.
+func (m *Manager) Doctor() ([]Check, error) {
+	var c checks
+
+	// git on PATH, dots falls back to its built-in git without it
+	if path, err := exec.LookPath("git"); err != nil {
+		c.warn("install git, e.g. 'sudo apt install git' or 'brew install git'", "git is not installed, dots uses its built-in git, which cannot stash or merge")
+	} else {
+		c.ok("git found at %s", path)
+	}
+	repo, err := m.Repo()
+	if err != nil {
+		c.fail("set DOTS_GIT to exec or builtin, or unset it", "%v", err)
+	}
+
+	// $EDITOR
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vim"
+	}
+	if path, err := exec.LookPath(editor); err != nil {
+		c.warn("set EDITOR to an installed editor, e.g. 'export EDITOR=nano'", "editor %q not found, 'dots edit' will not work", editor)
+	} else {
+		c.ok("editor found at %s", path)
+	}
+
+	// dots directory
+	if _, err := os.Stat(m.dir); os.IsNotExist(err) {
+		c.fail("run 'dots init' or 'dots clone <url>'", "dots directory not found: %s", m.dir)
+		return c, nil
+	}
+	c.ok("dots directory exists: %s", m.dir)
+
+	if _, err := os.Stat(filepath.Join(m.dir, ".git")); os.IsNotExist(err) {
+		c.fail(fmt.Sprintf("run 'git init' in %s", m.dir), "dots directory is not a git repository")
+	} else {
+		c.ok("dots directory is a git repository")
+
+		if repo != nil {
+			if url, err := repo.RemoteURL(); err != nil {
+				c.warn(fmt.Sprintf("cd %s && git remote add origin <url>", m.dir), "no remote configured, 'dots sync' cannot push")
+			} else {
+				c.ok("remote origin: %s", url)
+			}
+		}
+	}
+
+	// tracked links
+	tracked, err := m.Tracked()
+	if err != nil {
+		c.fail(fmt.Sprintf("fix %s", filepath.Join(m.dir, manifestName)), "cannot list tracked dotfiles: %v", err)
+	} else {
+		m.checkLinks(&c, tracked)
+	}
+
+	// files in the dots directory
+	if err := m.checkFiles(&c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkLinks flags dangling links and links leaving the dots directory
+func (m *Manager) checkLinks(c *checks, tracked []Dotfile) {
+	problems := 0
+	for _, df := range tracked {
+		link, err := os.Readlink(df.Target)
+		if err != nil {
+			continue
+		}
+
+		resolved := ResolveLink(df.Target, link)
+		if _, err := os.Stat(resolved); err != nil {
+			problems++
+			c.fail(fmt.Sprintf("remove it and run 'dots link' again: rm %s", df.Target), "dangling link: %s -> %s", df.Target, link)
+			continue
+		}
+
+		if !isWithin(resolved, m.dir) {
+			problems++
+			// A bare file name may match several dotfiles, the path in the
+			// dots directory names one
+			relPath, _ := filepath.Rel(m.dir, df.Source)
+			c.warn(fmt.Sprintf("replace it with a link into the dots directory: rm %s && dots link %s", df.Target, relPath),
+				"link points outside the dots directory: %s -> %s", df.Target, link)
+		}
+	}
+
+	if problems == 0 {
+		c.ok("%d tracked dotfiles checked, no broken links", len(tracked))
+	}
+}
+
+// checkFiles flags world-writable files and files git will never commit
+func (m *Manager) checkFiles(c *checks) error {
+	gitignore, err := loadGitignore(m.dir)
+	if err != nil {
+		return err
+	}
+	problems := 0
+
+	err = filepath.WalkDir(m.dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == m.dir {
+			return nil
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		relPath, _ := filepath.Rel(m.dir, path)
+
+		if gitignore.Match(filepath.ToSlash(relPath), entry.IsDir()) {
+			problems++
+			c.warn(fmt.Sprintf("delete it or move it out: rm -r %s", path), "%s is ignored by .gitignore and will never be synced", relPath)
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0o002 != 0 {
+			problems++
+			c.fail(fmt.Sprintf("chmod o-w %s", path), "%s is world-writable", relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking dots directory: %w", err)
+	}
+
+	if problems == 0 {
+		c.ok("no world-writable or ignored files in the dots directory")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package dots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	t.Setenv("EDITOR", "sh")
+	m := newTestManager(t, Options{Git: "builtin"})
+	writeTestFile(t, filepath.Join(m.dir, ".config", "nvim", "init.lua"), "nvim\n")
+	writeTestFile(t, filepath.Join(m.dir, ".vim", "init.lua"), "vim\n")
+	writeTestFile(t, filepath.Join(m.dir, ".bashrc"), "bash\n")
+	writeTestFile(t, filepath.Join(m.dir, ".zshrc"), "zsh\n")
+	writeTestFile(t, filepath.Join(m.dir, "notes.log"), "log\n")
+	// Only the repository's own .gitignore counts, not the default one
+	writeTestFile(t, filepath.Join(m.dir, "old.bak"), "bak\n")
+	writeTestFile(t, filepath.Join(m.dir, ".gitignore"), "*.log\n")
+	if err := os.Chmod(filepath.Join(m.dir, ".zshrc"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "init.lua")
+	writeTestFile(t, outside, "elsewhere\n")
+	nvim := filepath.Join(m.home, ".config", "nvim", "init.lua")
+	if err := os.MkdirAll(filepath.Dir(nvim), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, nvim); err != nil {
+		t.Fatal(err)
+	}
+	bashrc := filepath.Join(m.home, ".bashrc")
+	if err := os.Symlink(filepath.Join(m.home, "gone"), bashrc); err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := m.Doctor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var problems []Check
+	for _, check := range checks {
+		// Whether git is installed depends on the machine
+		if check.Level != CheckOK && !strings.HasPrefix(check.Message, "git is not installed") {
+			problems = append(problems, check)
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Message < problems[j].Message })
+	want := []Check{
+		{CheckFail, ".zshrc is world-writable", "chmod o-w " + filepath.Join(m.dir, ".zshrc")},
+		{CheckFail, "dangling link: " + bashrc + " -> " + filepath.Join(m.home, "gone"), "remove it and run 'dots link' again: rm " + bashrc},
+		{CheckFail, "dots directory is not a git repository", "run 'git init' in " + m.dir},
+		{CheckWarn, "link points outside the dots directory: " + nvim + " -> " + outside,
+			"replace it with a link into the dots directory: rm " + nvim + " && dots link .config/nvim/init.lua"},
+		{CheckWarn, "notes.log is ignored by .gitignore and will never be synced", "delete it or move it out: rm -r " + filepath.Join(m.dir, "notes.log")},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems =\n%+v\nwant\n%+v", problems, want)
+	}
+}
+
+func TestDoctorMissingDir(t *testing.T) {
+	m := newTestManager(t, Options{Git: "builtin"})
+
+	checks, err := m.Doctor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := checks[len(checks)-1]
+	if last.Level != CheckFail || last.Message != "dots directory not found: "+m.dir {
+		t.Errorf("last check = %+v, want the missing dots directory", last)
+	}
+}
@@ -0,0 +1,60 @@
+package dots
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by a Manager, wrapped with details. Match them with
+// errors.Is.
+var (
+	// ErrNoDotsDir means the dots directory does not exist
+	ErrNoDotsDir = errors.New("dots directory not found")
+	// ErrNotRepo means the dots directory is not a git repository
+	ErrNotRepo = errors.New("not a git repository")
+	// ErrNotTracked means a name resolves to no tracked dotfile
+	ErrNotTracked = errors.New("not tracked by dots")
+	// ErrExcluded means a path is excluded by .dotsignore
+	ErrExcluded = errors.New("excluded by " + ignoreName)
+	// ErrExists means a path to be added is tracked already
+	ErrExists = errors.New("already exists in dots directory")
+	// ErrNoRemote means the repository has no origin remote
+	ErrNoRemote = errors.New("no remote repository configured")
+	// ErrUncommitted means the repository has uncommitted changes
+	ErrUncommitted = errors.New("uncommitted changes detected")
+	// ErrBusy means another dots operation holds the journal
+	ErrBusy = errors.New("another dots operation is in progress")
+	// ErrNoPassphrase means encrypted dotfiles cannot be opened
+	ErrNoPassphrase = errors.New("no passphrase available")
+	// ErrBadPassphrase means an encrypted dotfile could not be decrypted
+	ErrBadPassphrase = errors.New("wrong passphrase or corrupted file")
+	// ErrHookFailed means a pre-hook failed and the operation was aborted
+	ErrHookFailed = errors.New("hook failed")
+	// ErrDiverged means the local branch and its upstream both have commits
+	// the other lacks
+	ErrDiverged = errors.New("local and remote history have diverged")
+	// ErrPullConflict means a pull stopped at conflicts and was aborted
+	ErrPullConflict = errors.New("pull stopped at conflicts")
+)
+
+// AmbiguousError is returned when a name matches several dotfiles
+type AmbiguousError struct {
+	Name    string
+	Matches []string // paths relative to the dots directory
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("'%s' matches several dotfiles, use one of their paths:\n  %s", e.Name, strings.Join(e.Matches, "\n  "))
+}
+
+// ConflictError is returned for a dotfile whose target holds something dots
+// did not put there
+type ConflictError struct {
+	Target string
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Target)
+}
@@ -0,0 +1,234 @@
+package dots
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Find resolves name to a tracked dotfile, file or directory. Entries
+// declared in dots.yaml come first. Otherwise name is looked up as a path in
+// the dots directory, a path in the home directory such as
+// ~/.config/nvim/init.lua, and finally as the trailing part of a tracked path
+// such as init.lua or nvim/init.lua. A name matching several dotfiles is an
+// *AmbiguousError listing all of them, paths excluded by .dotsignore are
+// never found.
+func (m *Manager) Find(name string) (*Dotfile, error) {
+	dotsDir, home := m.dir, m.home
+
+	declared, err := m.lookupDeclared(name)
+	if err != nil || declared != nil {
+		return declared, err
+	}
+
+	layout := func(relPath string) *Dotfile {
+		return &Dotfile{
+			Source: filepath.Join(dotsDir, relPath),
+			Target: targetName(filepath.Join(home, relPath)),
+		}
+	}
+
+	ignore, err := loadIgnore(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// An exact path, in the dots directory or in home
+	relPath := filepath.Clean(name)
+	if expanded := expandHome(name, home); filepath.IsAbs(expanded) {
+		switch {
+		case isWithin(expanded, dotsDir):
+			relPath, _ = filepath.Rel(dotsDir, expanded)
+		case isWithin(expanded, home):
+			relPath, _ = filepath.Rel(home, expanded)
+		default:
+			relPath = ""
+		}
+	}
+	if relPath != "" && relPath != "." && !strings.HasPrefix(relPath, "..") && !isMetaFile(strings.Split(relPath, string(filepath.Separator))[0]) {
+		// Generated dotfiles are found by the name of the file they write to
+		for _, candidate := range append([]string{relPath}, generatedNames(relPath)...) {
+			info, err := os.Lstat(filepath.Join(dotsDir, candidate))
+			if err != nil {
+				continue
+			}
+			if ignore.Excludes(candidate, info.IsDir()) {
+				return nil, fmt.Errorf("'%s' is %w", name, ErrExcluded)
+			}
+			return layout(candidate), nil
+		}
+	}
+
+	// The trailing part of a tracked path
+	suffix := filepath.Clean(name)
+	var declaredSources []string
+	manifest, err := loadManifest(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		for _, entry := range manifest.allEntries() {
+			declaredSources = append(declaredSources, entry.sourcePath(dotsDir))
+		}
+	}
+
+	var matches []string
+	walkErr := filepath.WalkDir(dotsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Return error to stop walk on failures
+			return err
+		}
+		if path == dotsDir {
+			return nil
+		}
+
+		// Skip meta files at the root of the dots directory
+		if filepath.Dir(path) == dotsDir && isMetaFile(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, source := range declaredSources {
+			if isWithin(path, source) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(dotsDir, path)
+		if err != nil {
+			return err
+		}
+		if ignore.Excludes(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, candidate := range []string{rel, targetName(rel)} {
+			if candidate == suffix || strings.HasSuffix(candidate, string(filepath.Separator)+suffix) {
+				matches = append(matches, rel)
+				break
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("error walking dots directory: %w", walkErr)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("'%s' is %w", name, ErrNotTracked)
+	case 1:
+		return layout(matches[0]), nil
+	}
+	return nil, &AmbiguousError{Name: name, Matches: matches}
+}
+
+// generatedNames returns the paths a generated dotfile writing to path may
+// have in the dots directory
+func generatedNames(path string) []string {
+	if isGenerated(path) {
+		return nil
+	}
+	names := make([]string, 0, len(generatedExts))
+	for _, ext := range generatedExts {
+		names = append(names, path+ext)
+	}
+	return names
+}
+
+// isMetaFile reports whether name is one of the files dots keeps at the root
+// of the dots directory for itself rather than as a dotfile
+func isMetaFile(name string) bool {
+	switch name {
+	case ".git", ".gitignore", "README.md", manifestName, ignoreName, hooksDir:
+		return true
+	}
+	return false
+}
+
+// generatedExts mark dotfiles that are written to their target rather than
+// linked. The target is the path without the extension.
+var generatedExts = []string{templateExt, encryptedExt}
+
+// targetName strips a generated extension from path
+func targetName(path string) string {
+	for _, ext := range generatedExts {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// isGenerated reports whether the dotfile at path is written to its target
+func isGenerated(path string) bool {
+	return targetName(path) != path
+}
+
+// writeDeployed atomically writes content to target unless it already holds
+// exactly that content with the given permissions. It reports whether the
+// target changed.
+func (m *Manager) writeDeployed(target string, content []byte, perm os.FileMode) (bool, error) {
+	if info, err := os.Lstat(target); err == nil {
+		if !info.Mode().IsRegular() {
+			return false, fmt.Errorf("target exists and is not a regular file: %s", target)
+		}
+		current, err := os.ReadFile(target)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(content, current) && info.Mode().Perm() == perm {
+			return false, nil
+		}
+	}
+
+	if err := m.mkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return false, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if m.opts.DryRun {
+		m.planf("write %s (%d bytes, mode %o)", target, len(content), perm)
+		return true, nil
+	}
+
+	if err := writeAtomic(target, bytes.NewReader(content), perm, time.Time{}); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return true, nil
+}
+
+// ResolveLink returns the absolute path a symlink at path with the given
+// content points to. Relative links are relative to the link's directory.
+func ResolveLink(path, link string) string {
+	if filepath.IsAbs(link) {
+		return filepath.Clean(link)
+	}
+	return filepath.Join(filepath.Dir(path), link)
+}
+
+// linkContent returns what the symlink at path pointing to src should hold:
+// src itself, or when relative its path relative to the link's directory
+func linkContent(src, path string, relative bool) string {
+	if !relative {
+		return src
+	}
+	rel, err := filepath.Rel(filepath.Dir(path), src)
+	if err != nil {
+		return src
+	}
+	return rel
+}
+
+// isWithin reports whether path is dir or lies inside it
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,412 @@
+package dots
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A tracked directory is either folded, a single symlink to the directory in
+// the dots directory, or unfolded, a real directory holding a symlink per
+// file. Folded directories are listed under folded in dots.yaml.
+
+// FoldResult is the outcome of Fold and Unfold
+type FoldResult struct {
+	Dotfile Dotfile
+	Changed bool // false when the directory already was linked that way
+	// Generated counts the rendered or decrypted files an unfolded directory
+	// holds, they are only written by Link
+	Generated int
+}
+
+// isFolded reports whether the directory dir of the dots directory, at
+// relPath, is linked as a whole: it is listed as folded, or target already
+// is a link to it.
+func isFolded(folded []string, relPath, dir, target string) bool {
+	for _, entry := range folded {
+		if filepath.Clean(entry) == relPath {
+			return true
+		}
+	}
+	link, err := os.Readlink(target)
+	return err == nil && ResolveLink(target, link) == dir
+}
+
+// findDirectory resolves name to a directory tracked through the layout
+func (m *Manager) findDirectory(name string) (*Dotfile, string, error) {
+	df, err := m.Find(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if df.Entry != nil {
+		return nil, "", fmt.Errorf("'%s' is declared in %s, its entry decides how it is linked", name, manifestName)
+	}
+	if info, err := os.Stat(df.Source); err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("'%s' is not a directory", name)
+	}
+
+	relPath, err := filepath.Rel(m.dir, df.Source)
+	if err != nil {
+		return nil, "", err
+	}
+	return df, relPath, nil
+}
+
+// scanUnfolded sorts the files below the real directory target into links
+// to their counterpart in source and everything else
+func scanUnfolded(source, target string) (linked []Dotfile, foreign []string, err error) {
+	err = filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(target, path)
+		repoPath := filepath.Join(source, relPath)
+		if link, err := os.Readlink(path); err == nil && ResolveLink(path, link) == repoPath {
+			linked = append(linked, Dotfile{Source: repoPath, Target: path})
+		} else {
+			foreign = append(foreign, path)
+		}
+		return nil
+	})
+	return linked, foreign, err
+}
+
+// Fold replaces the per-file links of an unfolded directory with one link.
+// Files in it that dots does not track stop it, so do paths in the dots
+// directory excluded by .dotsignore, the link would expose them.
+func (m *Manager) Fold(name string) (*FoldResult, error) {
+	df, relPath, err := m.findDirectory(name)
+	if err != nil {
+		return nil, err
+	}
+	result := &FoldResult{Dotfile: *df}
+
+	// Generated files need writing, a folded directory exposes the repo as is
+	err = filepath.WalkDir(df.Source, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && isGenerated(path) {
+			return fmt.Errorf("cannot fold %s, %s is rendered or decrypted rather than linked", relPath, path)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A folded directory would expose excluded paths through its link
+	ignore, err := loadIgnore(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := ignore.excludedBelow(df.Source, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", df.Source, err)
+	}
+	if len(excluded) > 0 {
+		return nil, fmt.Errorf("cannot fold %s, it holds paths excluded by %s:\n  %s", relPath, ignoreName, strings.Join(excluded, "\n  "))
+	}
+
+	exists := false
+	info, err := os.Lstat(df.Target)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeSymlink != 0:
+		link, _ := os.Readlink(df.Target)
+		if ResolveLink(df.Target, link) != df.Source {
+			return nil, fmt.Errorf("%s points to %s, not %s", df.Target, link, df.Source)
+		}
+		return result, m.setFolded(relPath, true)
+	case info.IsDir():
+		_, foreign, err := scanUnfolded(df.Source, df.Target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", df.Target, err)
+		}
+		if len(foreign) > 0 {
+			return nil, fmt.Errorf("%s holds files dots does not track, add or move them first:\n  %s", df.Target, strings.Join(foreign, "\n  "))
+		}
+		exists = true
+	default:
+		return nil, fmt.Errorf("%s exists and is not a directory", df.Target)
+	}
+
+	tx, err := m.beginTransaction("fold " + df.Target)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.run(func() error {
+		if exists {
+			if err := tx.remove(df.Target); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", df.Target, err)
+			}
+		}
+		if err := tx.mkdirAll(filepath.Dir(df.Target)); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := tx.symlink(df.Source, df.Target); err != nil {
+			return fmt.Errorf("failed to create symlink: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setFolded(relPath, true); err != nil {
+		return nil, err
+	}
+
+	result.Changed = true
+	return result, nil
+}
+
+// Unfold replaces the link to a folded directory with a real directory
+// holding a symlink per file, so other programs can keep their own files
+// next to the tracked ones. Paths excluded by .dotsignore are not linked.
+func (m *Manager) Unfold(name string) (*FoldResult, error) {
+	df, relPath, err := m.findDirectory(name)
+	if err != nil {
+		return nil, err
+	}
+	result := &FoldResult{Dotfile: *df}
+
+	ignore, err := loadIgnore(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	linked := false
+	info, err := os.Lstat(df.Target)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeSymlink != 0:
+		link, _ := os.Readlink(df.Target)
+		if ResolveLink(df.Target, link) != df.Source {
+			return nil, fmt.Errorf("%s points to %s, not %s", df.Target, link, df.Source)
+		}
+		linked = true
+	case info.IsDir():
+		return result, m.setFolded(relPath, false)
+	default:
+		return nil, fmt.Errorf("%s exists and is not a directory", df.Target)
+	}
+
+	tx, err := m.beginTransaction("unfold " + df.Target)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.run(func() error {
+		if linked {
+			if err := tx.remove(df.Target); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", df.Target, err)
+			}
+		}
+		return filepath.WalkDir(df.Source, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(df.Source, path)
+			target := filepath.Join(df.Target, rel)
+
+			if path != df.Source && ignore.Excludes(filepath.Join(relPath, rel), d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if err := tx.mkdirAll(target); err != nil {
+					return fmt.Errorf("failed to create %s: %w", target, err)
+				}
+				return nil
+			}
+			if isGenerated(path) {
+				result.Generated++
+				return nil
+			}
+			if err := tx.symlink(path, target); err != nil {
+				return fmt.Errorf("failed to link %s: %w", target, err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setFolded(relPath, false); err != nil {
+		return nil, err
+	}
+
+	result.Changed = true
+	return result, nil
+}
+
+// setFolded adds relPath to or removes it from the folded directories in
+// dots.yaml. Only the lines of the folded list are touched, the rest of the
+// file keeps its formatting and comments. An empty list is dropped, and so is
+// a manifest left with nothing else in it.
+func (m *Manager) setFolded(relPath string, fold bool) error {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	data, err := os.ReadFile(filepath.Join(m.dir, manifestName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", manifestName, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", manifestName, err)
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+	var root, key, list *yaml.Node
+	next := len(lines) // where what follows the folded key starts
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("failed to parse %s: not a mapping", manifestName)
+		}
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "folded" {
+				key, list = root.Content[i], root.Content[i+1]
+				if i+2 < len(root.Content) {
+					next = root.Content[i+2].Line - 1
+				}
+				break
+			}
+		}
+	}
+	if list != nil && list.Kind != yaml.SequenceNode && list.Tag != "!!null" {
+		return fmt.Errorf("failed to parse %s: folded is not a list", manifestName)
+	}
+
+	found := -1
+	var items []string
+	if list != nil {
+		for i, item := range list.Content {
+			if filepath.ToSlash(filepath.Clean(item.Value)) == relPath {
+				found = i
+			}
+			items = append(items, item.Value)
+		}
+	}
+	if (found >= 0) == fold {
+		return nil
+	}
+
+	var updated []string
+	switch {
+	case key == nil:
+		// A new section at the end
+		updated = lines
+		if len(updated) > 0 {
+			updated = append(updated, "")
+		}
+		section, err := foldedSection(0, []string{relPath})
+		if err != nil {
+			return err
+		}
+		updated = append(updated, section...)
+
+	case list.Kind == yaml.SequenceNode && list.Style&yaml.FlowStyle == 0:
+		// A block list gains or loses the line of one item
+		var start, end int
+		var insert []string
+		if fold {
+			last := list.Content[len(list.Content)-1]
+			start = blockEnd(lines, last.Line-1, -1)
+			end = start
+			prefix := lines[last.Line-1][:last.Column-1]
+			if strings.Trim(prefix, " \t") != "-" {
+				prefix = strings.Repeat(" ", key.Column+1) + "- "
+			}
+			quoted, err := quoteYAML(relPath)
+			if err != nil {
+				return err
+			}
+			insert = []string{prefix + quoted}
+		} else if len(list.Content) == 1 {
+			// The last item takes the key along
+			start, end = key.Line-1, blockEnd(lines, key.Line-1, next)
+		} else {
+			item := list.Content[found]
+			limit := -1
+			if found+1 < len(list.Content) {
+				limit = list.Content[found+1].Line - 1
+			}
+			start, end = item.Line-1, blockEnd(lines, item.Line-1, limit)
+		}
+		updated = append(updated, lines[:start]...)
+		updated = append(updated, insert...)
+		updated = append(updated, lines[end:]...)
+
+	default:
+		// A flow or empty list is written anew as a block list
+		if fold {
+			items = append(items, relPath)
+		} else {
+			items = append(items[:found], items[found+1:]...)
+		}
+		section, err := foldedSection(key.Column-1, items)
+		if err != nil {
+			return err
+		}
+		end := blockEnd(lines, key.Line-1, next)
+		updated = append(updated, lines[:key.Line-1]...)
+		updated = append(updated, section...)
+		updated = append(updated, lines[end:]...)
+	}
+
+	content := strings.Join(updated, "\n")
+	if err := m.writeManifest(content); err != nil {
+		return err
+	}
+	switch {
+	case len(data) == 0:
+		m.logf("✓ Created %s listing %s as folded", manifestName, relPath)
+	case strings.TrimSpace(content) == "":
+		m.logf("✓ Removed %s, %s was all it listed", manifestName, relPath)
+	case fold:
+		m.logf("✓ Listed %s as folded in %s", relPath, manifestName)
+	default:
+		m.logf("✓ Removed %s from the folded directories in %s", relPath, manifestName)
+	}
+	return nil
+}
+
+// foldedSection renders the folded key indented by indent with items as a
+// block list, or nothing without items
+func foldedSection(indent int, items []string) ([]string, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	pad := strings.Repeat(" ", indent)
+	section := []string{pad + "folded:"}
+	for _, item := range items {
+		quoted, err := quoteYAML(item)
+		if err != nil {
+			return nil, err
+		}
+		section = append(section, pad+"  - "+quoted)
+	}
+	return section, nil
+}
+
+// quoteYAML renders s as a YAML scalar, quoted only when it needs to be
+func quoteYAML(s string) (string, error) {
+	quoted, err := yaml.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(quoted)), nil
+}
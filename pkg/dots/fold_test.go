@@ -0,0 +1,151 @@
+package dots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetFolded(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string // empty for no dots.yaml
+		relPath  string
+		fold     bool
+		want     string // empty for no dots.yaml
+	}{
+		{
+			name:    "create",
+			relPath: ".config/nvim",
+			fold:    true,
+			want:    "folded:\n  - .config/nvim\n",
+		},
+		{
+			name:     "append",
+			manifest: "# comment\nfolded:\n  - .config/nvim\n\nhooks:\n  post-pull: [tmux source ~/.tmux.conf]\n",
+			relPath:  ".config/kitty",
+			fold:     true,
+			want:     "# comment\nfolded:\n  - .config/nvim\n  - .config/kitty\n\nhooks:\n  post-pull: [tmux source ~/.tmux.conf]\n",
+		},
+		{
+			name:     "flow list",
+			manifest: "folded: [.config/nvim]\n",
+			relPath:  ".config/kitty",
+			fold:     true,
+			want:     "folded:\n  - .config/nvim\n  - .config/kitty\n",
+		},
+		{
+			name:     "unfold one",
+			manifest: "folded:\n  - .config/nvim\n  - .config/kitty\n",
+			relPath:  ".config/nvim",
+			want:     "folded:\n  - .config/kitty\n",
+		},
+		{
+			name:     "unfold the last drops the section",
+			manifest: "dotfiles:\n  - source: vimrc\n    target: ~/.vimrc\n\nfolded:\n  - .config/nvim\n",
+			relPath:  ".config/nvim",
+			want:     "dotfiles:\n  - source: vimrc\n    target: ~/.vimrc\n",
+		},
+		{
+			name:     "unfold the last removes the manifest",
+			manifest: "folded:\n  - .config/nvim\n",
+			relPath:  ".config/nvim",
+		},
+		{
+			name:    "unfold without a manifest",
+			relPath: ".config/nvim",
+		},
+		{
+			name:     "comments in the list are kept",
+			manifest: "folded:\n  # editors\n  - .config/nvim # main\n  - .config/helix\n  # terminals\n  - .config/kitty\n\n# hooks\nhooks: {}\n",
+			relPath:  ".config/helix",
+			want:     "folded:\n  # editors\n  - .config/nvim # main\n  # terminals\n  - .config/kitty\n\n# hooks\nhooks: {}\n",
+		},
+		{
+			name:     "append after a trailing comment",
+			manifest: "folded:\n  - .config/nvim\n  # more to come\nhooks: {}\n",
+			relPath:  "my dir",
+			fold:     true,
+			want:     "folded:\n  - .config/nvim\n  - my dir\n  # more to come\nhooks: {}\n",
+		},
+		{
+			name:     "indented and quoted key",
+			manifest: "  \"folded\":\n      - .config/nvim\n  hooks: {}\n",
+			relPath:  ".config/kitty",
+			fold:     true,
+			want:     "  \"folded\":\n      - .config/nvim\n      - .config/kitty\n  hooks: {}\n",
+		},
+		{
+			name:     "flow list over several lines",
+			manifest: "folded: [\n  .config/nvim,\n  .config/kitty,\n]\nhooks: {}\n",
+			relPath:  ".config/nvim",
+			want:     "folded:\n  - .config/kitty\nhooks: {}\n",
+		},
+		{
+			name:     "empty flow list",
+			manifest: "folded: []\nhooks: {}\n",
+			relPath:  ".config/nvim",
+			fold:     true,
+			want:     "folded:\n  - .config/nvim\nhooks: {}\n",
+		},
+		{
+			name:     "empty key",
+			manifest: "folded:\n# nothing yet\nhooks: {}\n",
+			relPath:  ".config/nvim",
+			fold:     true,
+			want:     "folded:\n  - .config/nvim\n# nothing yet\nhooks: {}\n",
+		},
+		{
+			name:     "manifest of comments",
+			manifest: "# dots\n",
+			relPath:  ".config/nvim",
+			fold:     true,
+			want:     "# dots\n\nfolded:\n  - .config/nvim\n",
+		},
+		{
+			name:     "a folded key in a profile is not the list",
+			manifest: "profiles:\n  work:\n    folded:\n      - .config/nvim\n",
+			relPath:  ".config/nvim",
+			fold:     true,
+			want:     "profiles:\n  work:\n    folded:\n      - .config/nvim\n\nfolded:\n  - .config/nvim\n",
+		},
+		{
+			name:     "already folded",
+			manifest: "folded:\n- .config/nvim # editor\n",
+			relPath:  ".config/nvim",
+			fold:     true,
+			want:     "folded:\n- .config/nvim # editor\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, Options{})
+			path := filepath.Join(m.dir, manifestName)
+			if err := os.MkdirAll(m.dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.manifest != "" {
+				writeTestFile(t, path, tt.manifest)
+			}
+
+			if err := m.setFolded(tt.relPath, tt.fold); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("%s exists with %q, want none", manifestName, data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("%s is\n%s\nwant\n%s", manifestName, data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package dots
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// planf reports a planned action in dry-run mode
+func (m *Manager) planf(format string, args ...any) {
+	m.log.Planf(format, args...)
+}
+
+// logf reports progress that only makes sense once an action really happened
+func (m *Manager) logf(format string, args ...any) {
+	if !m.opts.DryRun {
+		m.log.Infof(format, args...)
+	}
+}
+
+// Every filesystem mutation goes through the helpers below, which only plan
+// the change in dry-run mode
+
+func (m *Manager) mkdirAll(path string, perm os.FileMode) error {
+	if m.opts.DryRun {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			m.planf("mkdir -p %s", path)
+		}
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+func (m *Manager) writeFile(path string, data []byte, perm os.FileMode) error {
+	if m.opts.DryRun {
+		m.planf("write %s (%d bytes)", path, len(data))
+		return nil
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+func (m *Manager) removeAll(path string) error {
+	if m.opts.DryRun {
+		m.planf("rm -rf %s", path)
+		return nil
+	}
+	return os.RemoveAll(path)
+}
+
+func (m *Manager) rename(oldpath, newpath string) error {
+	if m.opts.DryRun {
+		m.planf("mv %s %s", oldpath, newpath)
+		return nil
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (m *Manager) symlink(oldname, newname string) error {
+	if m.opts.DryRun {
+		m.planf("ln -s %s %s", oldname, newname)
+		return nil
+	}
+	return os.Symlink(oldname, newname)
+}
+
+func (m *Manager) hardlink(oldname, newname string) error {
+	if m.opts.DryRun {
+		m.planf("ln %s %s", oldname, newname)
+		return nil
+	}
+	return os.Link(oldname, newname)
+}
+
+// copyPath copies a file, symlink or whole directory tree, leaving out what
+// skip matches inside a directory
+func (m *Manager) copyPath(src, dst string, skip skipFunc) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if m.opts.DryRun {
+		if info.IsDir() && skip != nil {
+			m.planf("cp -a %s %s (leaving out excluded paths)", src, dst)
+		} else {
+			m.planf("cp -a %s %s", src, dst)
+		}
+		return nil
+	}
+
+	return m.copyTree(src, dst, skip)
+}
+
+// planGit reports a git command in dry-run mode
+func (m *Manager) planGit(dir string, args []string) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+
+	if dir == "" {
+		m.planf("git %s", strings.Join(quoted, " "))
+		return
+	}
+	m.planf("git -C %s %s", dir, strings.Join(quoted, " "))
+}
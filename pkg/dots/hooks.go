@@ -0,0 +1,382 @@
+package dots
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hook points, each hook runs before or after the operation it is named after
+const (
+	HookPreAdd    = "pre-add"
+	HookPostAdd   = "post-add"
+	HookPreLink   = "pre-link"
+	HookPostLink  = "post-link"
+	HookPreApply  = "pre-apply"
+	HookPostApply = "post-apply"
+	HookPreSync   = "pre-sync"
+	HookPostSync  = "post-sync"
+	HookPrePull   = "pre-pull"
+	HookPostPull  = "post-pull"
+)
+
+// hookPoints lists every hook point in the order they are documented
+var hookPoints = []string{
+	HookPreAdd, HookPostAdd,
+	HookPreLink, HookPostLink,
+	HookPreApply, HookPostApply,
+	HookPreSync, HookPostSync,
+	HookPrePull, HookPostPull,
+}
+
+// hooksDir holds hook scripts in the dots directory, named after their
+// hook point
+const hooksDir = "hooks"
+
+// defaultHookTimeout bounds hooks that set no timeout of their own
+const defaultHookTimeout = time.Minute
+
+// Hook is a shell command run at a hook point or when a dotfile changes. In
+// dots.yaml it is either the command itself or a mapping with run and
+// timeout.
+type Hook struct {
+	Run     string `yaml:"run"`
+	Timeout string `yaml:"timeout"`
+}
+
+// UnmarshalYAML accepts a plain command as well as a mapping
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		h.Run = node.Value
+		return nil
+	}
+	type plain Hook
+	return node.Decode((*plain)(h))
+}
+
+// validate checks the command and parses the timeout
+func (h Hook) validate() error {
+	if strings.TrimSpace(h.Run) == "" {
+		return fmt.Errorf("hook needs a command to run")
+	}
+	if h.Timeout != "" {
+		if _, err := parseHookTimeout(h.Timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseHookTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid hook timeout %q, want a positive duration such as 30s or 2m", value)
+	}
+	return timeout, nil
+}
+
+// validateHooks checks the hooks section of the manifest
+func validateHooks(manifest *Manifest) error {
+	if manifest.HookTimeout != "" {
+		if _, err := parseHookTimeout(manifest.HookTimeout); err != nil {
+			return fmt.Errorf("%s: hook_timeout: %w", manifestName, err)
+		}
+	}
+	for point, hooks := range manifest.Hooks {
+		if !isHookPoint(point) {
+			return fmt.Errorf("%s: unknown hook %q (want one of %s)", manifestName, point, strings.Join(hookPoints, ", "))
+		}
+		for i, hook := range hooks {
+			if err := hook.validate(); err != nil {
+				return fmt.Errorf("%s: %s hook %d: %w", manifestName, point, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isHookPoint(name string) bool {
+	for _, point := range hookPoints {
+		if point == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hookCommand is a hook ready to run
+type hookCommand struct {
+	name    string // what the hook is reported as
+	args    []string
+	timeout time.Duration
+}
+
+// hookTimeout returns the timeout of hook, falling back to hook_timeout of
+// the manifest and then to the default
+func hookTimeout(hook Hook, manifest *Manifest) time.Duration {
+	for _, value := range []string{hook.Timeout, manifestHookTimeout(manifest)} {
+		if value != "" {
+			if timeout, err := parseHookTimeout(value); err == nil {
+				return timeout
+			}
+		}
+	}
+	return defaultHookTimeout
+}
+
+func manifestHookTimeout(manifest *Manifest) string {
+	if manifest == nil {
+		return ""
+	}
+	return manifest.HookTimeout
+}
+
+// hooksFor collects the hooks of a hook point: the script in the hooks
+// directory first, followed by the commands declared in dots.yaml
+func (m *Manager) hooksFor(point string) ([]hookCommand, error) {
+	manifest, err := loadManifest(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []hookCommand
+	script := filepath.Join(m.dir, hooksDir, point)
+	if info, err := os.Stat(script); err == nil && info.Mode().IsRegular() {
+		if info.Mode().Perm()&0o111 == 0 {
+			m.log.Warnf("%s is not executable, skipping it (chmod +x it to run it)", script)
+		} else {
+			commands = append(commands, hookCommand{
+				name:    filepath.Join(hooksDir, point),
+				args:    []string{script},
+				timeout: hookTimeout(Hook{}, manifest),
+			})
+		}
+	}
+
+	if manifest != nil {
+		for _, hook := range manifest.Hooks[point] {
+			commands = append(commands, hookCommand{
+				name:    hook.Run,
+				args:    []string{"sh", "-c", hook.Run},
+				timeout: hookTimeout(hook, manifest),
+			})
+		}
+	}
+	return commands, nil
+}
+
+// hookEnv describes the operation to a hook. Changed holds the paths the
+// operation changed, the targets of dotfiles or paths in the dots directory.
+func (m *Manager) hookEnv(point string, changed []string) []string {
+	env := append(os.Environ(),
+		"DOTS_HOOK="+point,
+		"DOTS_DIR="+m.dir,
+		"DOTS_HOME="+m.home,
+		"DOTS_CHANGED="+strings.Join(changed, "\n"),
+	)
+	if manifest, err := loadManifest(m.dir); err == nil && manifest != nil {
+		if profiles, err := manifest.activeProfiles(m.opts.Profiles); err == nil {
+			env = append(env, "DOTS_PROFILES="+strings.Join(profiles, ","))
+		}
+	}
+	return env
+}
+
+// runHooks runs every hook of a hook point. A failing pre-hook aborts the
+// operation and is returned, a failing post-hook is only reported.
+func (m *Manager) runHooks(point string, changed []string) error {
+	if m.opts.NoHooks {
+		return nil
+	}
+
+	commands, err := m.hooksFor(point)
+	if err != nil {
+		return err
+	}
+
+	env := m.hookEnv(point, changed)
+	for _, command := range commands {
+		if err := m.runHook(command, env); err != nil {
+			if strings.HasPrefix(point, "pre-") {
+				return fmt.Errorf("%s %w: %s: %v", point, ErrHookFailed, command.name, err)
+			}
+			m.log.Warnf("%s hook failed: %s: %v", point, command.name, err)
+		}
+	}
+	return nil
+}
+
+// runOnChange runs the on_change command of a declared dotfile whose target
+// changed. Failures are only reported.
+func (m *Manager) runOnChange(df Dotfile) {
+	if m.opts.NoHooks || df.Entry == nil || df.Entry.OnChange == nil {
+		return
+	}
+
+	manifest, _ := loadManifest(m.dir)
+	hook := *df.Entry.OnChange
+	command := hookCommand{
+		name:    hook.Run,
+		args:    []string{"sh", "-c", hook.Run},
+		timeout: hookTimeout(hook, manifest),
+	}
+	env := append(m.hookEnv("on_change", []string{df.Target}),
+		"DOTS_SOURCE="+df.Source,
+		"DOTS_TARGET="+df.Target,
+	)
+	if err := m.runHook(command, env); err != nil {
+		m.log.Warnf("on_change of %s failed: %s: %v", df.Target, command.name, err)
+	}
+}
+
+// runHook runs a single hook in the dots directory, killing it once its
+// timeout expires
+func (m *Manager) runHook(command hookCommand, env []string) error {
+	if m.opts.DryRun {
+		m.planf("run hook %s", command.name)
+		return nil
+	}
+	m.log.Infof("Running hook: %s", command.name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command.args[0], command.args[1:]...)
+	cmd.Dir = m.dir
+	cmd.Env = env
+	cmd.Stdout = m.opts.HookOutput
+	cmd.Stderr = m.opts.HookOutput
+	// Do not wait for children holding the output open after a kill
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", command.timeout)
+	}
+	return err
+}
+
+// wantsPullChanges reports whether anything would use the dotfiles a pull
+// changed, which takes hashing every source before and after
+func (m *Manager) wantsPullChanges() (bool, error) {
+	if m.opts.NoHooks {
+		return false, nil
+	}
+	commands, err := m.hooksFor(HookPostPull)
+	if err != nil || len(commands) > 0 {
+		return len(commands) > 0, err
+	}
+
+	manifest, err := loadManifest(m.dir)
+	if err != nil || manifest == nil {
+		return false, err
+	}
+	for _, entry := range manifest.allEntries() {
+		if entry.OnChange != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// snapshotSources maps the target of every tracked dotfile to a hash of its
+// source
+func (m *Manager) snapshotSources() (map[string]string, error) {
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]string, len(tracked))
+	for _, df := range tracked {
+		hash, err := hashSource(df.Source)
+		if err != nil {
+			return nil, err
+		}
+		snapshot[df.Target] = hash
+	}
+	return snapshot, nil
+}
+
+// changedSince compares the tracked dotfiles with an earlier snapshot. It
+// returns the targets whose source was added, changed or removed, and the
+// changed dotfiles that are still tracked.
+func (m *Manager) changedSince(before map[string]string) ([]string, []Dotfile, error) {
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var targets []string
+	var changed []Dotfile
+	seen := map[string]bool{}
+	for _, df := range tracked {
+		seen[df.Target] = true
+		hash, err := hashSource(df.Source)
+		if err != nil {
+			return nil, nil, err
+		}
+		if old, ok := before[df.Target]; ok && old == hash {
+			continue
+		}
+		targets = append(targets, df.Target)
+		changed = append(changed, df)
+	}
+	for target := range before {
+		if !seen[target] {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets, changed, nil
+}
+
+// hashSource hashes a file, symlink or directory tree in the dots directory.
+// A missing source hashes to the empty string.
+func hashSource(source string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(source, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(source, path)
+		fmt.Fprintf(h, "%s\x00", rel)
+
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link:%s\x00", link)
+		case d.Type().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,212 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreName is the file at the root of the dots directory listing paths dots
+// neither tracks nor links, in .gitignore syntax
+const ignoreName = ".dotsignore"
+
+// ignoreRule is one pattern of a .dotsignore
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // the pattern started with !, re-including what it matches
+	dirOnly bool // the pattern ended with /, matching directories only
+}
+
+// IgnoreList is a parsed .dotsignore. Paths are relative to the dots
+// directory, or equally to the home directory.
+type IgnoreList []ignoreRule
+
+// loadIgnore reads the .dotsignore of the dots directory, if there is one
+func loadIgnore(dotsDir string) (IgnoreList, error) {
+	data, err := os.ReadFile(filepath.Join(dotsDir, ignoreName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreName, err)
+	}
+	rules, err := ParseIgnore(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", ignoreName, err)
+	}
+	return rules, nil
+}
+
+// loadGitignore parses the .gitignore of the dots directory, or the one Init
+// writes when there is none
+func loadGitignore(dotsDir string) (IgnoreList, error) {
+	content := DefaultGitignore
+	data, err := os.ReadFile(filepath.Join(dotsDir, ".gitignore"))
+	if err == nil {
+		content = string(data)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	gitignore, err := ParseIgnore(content)
+	if err != nil {
+		return nil, fmt.Errorf(".gitignore %w", err)
+	}
+	return gitignore, nil
+}
+
+// ParseIgnore parses patterns in .gitignore syntax
+func ParseIgnore(content string) (IgnoreList, error) {
+	var rules IgnoreList
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		pattern, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rule.pattern = pattern
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compileIgnorePattern turns a .gitignore pattern into a regular expression
+// matching slash separated paths. A pattern without a slash matches a name at
+// any depth, one with a slash is anchored to the root. "**" spans
+// directories.
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		re.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			atSegment := i == 0 || pattern[i-1] == '/'
+			if atSegment && strings.HasPrefix(pattern[i:], "**/") {
+				// Zero or more directories
+				re.WriteString("(?:.*/)?")
+				i += 2
+				continue
+			}
+			if atSegment && pattern[i:] == "**" {
+				// Everything below
+				re.WriteString(".*")
+				i++
+				continue
+			}
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return compiled, nil
+}
+
+// Match applies the rules to a single slash separated path. The last
+// matching rule wins.
+func (l IgnoreList) Match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range l {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(relPath) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Excludes reports whether relPath is excluded, by a rule matching it or
+// one of its parent directories. Like git, a path inside an excluded
+// directory cannot be re-included.
+func (l IgnoreList) Excludes(relPath string, isDir bool) bool {
+	if len(l) == 0 {
+		return false
+	}
+
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if l.Match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return l.Match(relPath, isDir)
+}
+
+// excludedBelow lists the paths below dir that the rules exclude, with dir
+// at relPath. Excluded directories are listed without their content.
+func (l IgnoreList) excludedBelow(dir, relPath string) ([]string, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+
+	var excluded []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if l.Excludes(filepath.Join(relPath, rel), d.IsDir()) {
+			excluded = append(excluded, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return excluded, err
+}
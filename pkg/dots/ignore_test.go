@@ -0,0 +1,98 @@
+package dots
+
+import "testing"
+
+func TestIgnoreExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		// A pattern without a slash matches a name at any depth
+		{"name at root", "*.log", "debug.log", false, true},
+		{"name below", "*.log", ".config/app/debug.log", false, true},
+		{"name differs", "*.log", ".config/app/debug.txt", false, false},
+		{"star stays in its segment", "app*", ".config/app/x", false, true},
+		{"question mark", "?.swp", ".a.swp", false, false},
+		{"question mark one char", "?.swp", "a.swp", false, true},
+
+		// A slash anchors the pattern to the root
+		{"anchored", "/secrets", "secrets", false, true},
+		{"anchored not below", "/secrets", ".config/secrets", false, false},
+		{"inner slash anchors", ".config/gh", ".config/gh", true, true},
+		{"inner slash not below", ".config/gh", "x/.config/gh", true, false},
+		{"star does not span directories", ".config/*.json", ".config/a/b.json", false, false},
+
+		// "**" spans directories
+		{"leading **", "**/cache", ".local/share/app/cache", true, true},
+		{"leading ** at root", "**/cache", "cache", true, true},
+		{"trailing **", ".config/app/**", ".config/app/a/b", false, true},
+		{"inner **", ".config/**/state.json", ".config/state.json", false, true},
+		{"inner ** deep", ".config/**/state.json", ".config/a/b/state.json", false, true},
+		{"inner ** other file", ".config/**/state.json", ".config/a/other.json", false, false},
+
+		// Character classes
+		{"class", "*.[oa]", "lib.a", false, true},
+		{"class miss", "*.[oa]", "lib.c", false, false},
+		{"range", "log[0-9]", "log7", false, true},
+		{"negated class", "log[!0-9]", "log7", false, false},
+		{"negated class miss", "log[!0-9]", "logx", false, true},
+		{"unclosed bracket is literal", "a[b", "a[b", false, true},
+		{"escaped star", `\*.txt`, "*.txt", false, true},
+		{"escaped star literal", `\*.txt`, "a.txt", false, false},
+
+		// Directory-only patterns
+		{"dir only matches dir", "build/", "build", true, true},
+		{"dir only skips file", "build/", "build", false, false},
+		{"dir only excludes content", "build/", "build/out.bin", false, true},
+		{"dir only below", "node_modules/", "app/node_modules/x.js", false, true},
+
+		// Negation, the last matching rule wins
+		{"negated", "*.log\n!keep.log", "keep.log", false, false},
+		{"negated others", "*.log\n!keep.log", "other.log", false, true},
+		{"negation order", "!keep.log\n*.log", "keep.log", false, true},
+		{"re-include under excluded parent", "build/\n!build/keep", "build/keep", false, true},
+		{"re-include under excluded content", "build/*\n!build/keep", "build/keep", false, false},
+		{"re-include sibling stays excluded", "build/*\n!build/keep", "build/other", false, true},
+
+		// Blank lines and comments
+		{"comment", "# *.log\n\n", "a.log", false, false},
+		{"trailing spaces", "*.log  ", "a.log", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseIgnore(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.Excludes(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("%q excludes %s = %v, want %v", tt.patterns, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatch(t *testing.T) {
+	// Match looks at the path alone, Excludes at its parents as well
+	rules, err := ParseIgnore("build/\n!build/keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Match("build/keep", false) {
+		t.Error("Match applied the rule of a parent directory")
+	}
+	if !rules.Match("build", true) {
+		t.Error("Match did not match the directory itself")
+	}
+	if !rules.Excludes("build/keep", false) {
+		t.Error("Excludes re-included a file under an excluded directory")
+	}
+
+	var empty IgnoreList
+	if empty.Excludes("anything", false) {
+		t.Error("an empty list excludes")
+	}
+}
@@ -0,0 +1,170 @@
+package dots
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultGitignore is the .gitignore written by Init
+const DefaultGitignore = `# Ignore backup files
+*.backup
+*.bak
+*.swp
+*.tmp
+
+# Ignore OS files
+.DS_Store
+Thumbs.db
+
+# Ignore editor files
+.vscode/
+.idea/
+*.sublime-*
+`
+
+// defaultDotsignore is the .dotsignore written by Init
+const defaultDotsignore = `# Paths dots neither adds, tracks nor links, in .gitignore syntax.
+# Patterns are matched against paths relative to the dots directory,
+# which mirror paths relative to your home directory.
+
+# Logs, caches and sockets
+*.log
+*.sock
+.cache/
+__pycache__/
+
+# Installed dependencies
+node_modules/
+`
+
+// defaultReadme is the README.md written by Init
+const defaultReadme = `# My Dotfiles
+
+This repository contains my personal dotfiles managed with [dots](https://github.com/Ethics03/Dots).
+
+## Setup
+
+To set up these dotfiles on a new machine:
+
+` + "```bash" + `
+# Clone this repository
+git clone <your-repo-url> ~/.config/dots
+
+# Link the dotfiles
+dots link bashrc
+dots link nvim
+` + "```" + `
+
+## Tracked Files
+
+All files in this directory (except .git, .gitignore, .dotsignore, dots.yaml,
+README.md, hooks/ and the paths .dotsignore excludes) are tracked dotfiles.
+`
+
+// Init creates the dots directory with its configuration files and commits
+// them to a new repository
+func (m *Manager) Init() error {
+	dotsDir := m.dir
+
+	// Check if dots directory already exists
+	if _, err := os.Stat(dotsDir); err == nil {
+		return fmt.Errorf("dots directory already exists at %s\nUse 'dots status' to check your dotfiles", dotsDir)
+	}
+
+	// Create dots directory
+	m.log.Infof("Setting up dotfiles directory...")
+	if err := m.mkdirAll(dotsDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create dots directory: %w", err)
+	}
+	m.logf("   ✓ Created %s", dotsDir)
+
+	// Create configuration files
+	m.log.Infof("Creating configuration files...")
+	files := []struct{ name, content string }{
+		{".gitignore", DefaultGitignore},
+		{ignoreName, defaultDotsignore},
+		{"README.md", defaultReadme},
+	}
+	for _, f := range files {
+		if err := m.writeFile(filepath.Join(dotsDir, f.name), []byte(f.content), 0o644); err != nil {
+			return fmt.Errorf("failed to create %s: %w", f.name, err)
+		}
+		m.logf("   ✓ %s", f.name)
+	}
+
+	// init git repo
+	m.log.Infof("Initializing git repository...")
+	repo, err := m.Repo()
+	if err != nil {
+		return err
+	}
+	if err := repo.Init(); err != nil {
+		return fmt.Errorf("failed to initialize git: %w", err)
+	}
+	m.logf("   ✓ Repository initialized")
+
+	// initial commit
+	m.log.Infof("Creating initial commit...")
+
+	// Stage all files
+	if err := repo.AddAll(); err != nil {
+		return fmt.Errorf("failed to stage files: %w", err)
+	}
+
+	// Commit
+	if err := repo.Commit("Initial commit: dots setup", nil); err != nil {
+		return fmt.Errorf("failed to create initial commit: %w", err)
+	}
+	m.logf("   ✓ Committed initial files")
+
+	return nil
+}
+
+// Clone clones an existing dotfiles repository into the dots directory and
+// returns its top-level dotfiles. Nothing is listed in dry-run mode.
+func (m *Manager) Clone(url string) ([]fs.DirEntry, error) {
+	dotsDir := m.dir
+
+	// Check if dots directory already exists
+	if _, err := os.Stat(dotsDir); err == nil {
+		return nil, fmt.Errorf("dots directory already exists at %s\nRemove it first or use 'dots pull' to update", dotsDir)
+	}
+
+	m.log.Infof("Cloning dotfiles from %s...", url)
+
+	// Clone the repository
+	repo, err := m.Repo()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Clone(url); err != nil {
+		return nil, fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	// Nothing to list when the clone was only planned
+	if m.opts.DryRun {
+		return nil, nil
+	}
+
+	files, err := os.ReadDir(dotsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dots directory: %w", err)
+	}
+
+	ignore, err := loadIgnore(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dotfiles []fs.DirEntry
+	for _, file := range files {
+		// Skip git directory, README, and other meta files
+		if isMetaFile(file.Name()) || ignore.Excludes(file.Name(), file.IsDir()) {
+			continue
+		}
+		dotfiles = append(dotfiles, file)
+	}
+	return dotfiles, nil
+}
@@ -0,0 +1,351 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LinkOptions configure Link and Apply
+type LinkOptions struct {
+	// All links every tracked dotfile instead of the named ones
+	All bool
+	// Mode deploys the dotfiles whose entry declares no mode, by default they
+	// keep the mode they were deployed with last
+	Mode DeployMode
+	// Relative makes links hold paths relative to their own directory.
+	// Existing absolute links to the right dotfile are converted.
+	Relative bool
+	// OnConflict decides what happens to something else at a target, by
+	// default it is skipped
+	OnConflict ConflictStrategy
+	// Ask picks the strategy for a single conflict under ConflictDiff,
+	// typically after showing the Diff. Without it conflicts are skipped.
+	Ask func(df Dotfile) ConflictStrategy
+}
+
+// LinkOutcome is what linking a single dotfile came to
+type LinkOutcome int
+
+const (
+	LinkCreated   LinkOutcome = iota // the target was linked, copied or written
+	LinkUnchanged                    // the target was already in place
+	LinkConflict                     // something else is at the target and was left alone
+	LinkFailed                       // the dotfile could not be deployed
+)
+
+// LinkResult is the outcome of linking a single dotfile
+type LinkResult struct {
+	Dotfile Dotfile
+	Outcome LinkOutcome
+	Err     error // the *ConflictError of a conflict, or why linking failed
+}
+
+// ApplyResult is the outcome of Apply
+type ApplyResult struct {
+	Profiles []string // the active profiles, starting with the default one
+	Results  []LinkResult
+}
+
+// Link deploys the named dotfiles to their targets, or every tracked
+// dotfile with opts.All. Names containing glob characters are matched
+// against the paths of the tracked dotfiles. A dotfile that cannot be
+// deployed does not stop the others, its result carries the error. The
+// pre-link and post-link hooks run around it.
+func (m *Manager) Link(names []string, opts LinkOptions) ([]LinkResult, error) {
+	if err := m.checkDir(); err != nil {
+		return nil, err
+	}
+	if err := m.runHooks(HookPreLink, nil); err != nil {
+		return nil, err
+	}
+
+	selected, err := m.selectDotfiles(names, opts.All)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveModes(selected, opts.Mode); err != nil {
+		return nil, err
+	}
+	results, err := m.linkAll(selected, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.runHooks(HookPostLink, createdTargets(results))
+	return results, nil
+}
+
+// Apply deploys every dotfile declared in dots.yaml for the active profiles.
+// Without a manifest or declared dotfiles there is nothing to apply. The
+// pre-apply and post-apply hooks run around it.
+func (m *Manager) Apply(opts LinkOptions) (*ApplyResult, error) {
+	if err := m.checkDir(); err != nil {
+		return nil, err
+	}
+	if err := m.runHooks(HookPreApply, nil); err != nil {
+		return nil, err
+	}
+
+	manifest, err := loadManifest(m.dir)
+	if err != nil || manifest == nil {
+		return &ApplyResult{}, err
+	}
+
+	profiles, err := manifest.activeProfiles(m.opts.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := manifest.selectedEntries(m.opts.Profiles)
+	if err != nil || len(entries) == 0 {
+		return &ApplyResult{Profiles: profiles}, err
+	}
+	m.log.Infof("Profiles: %s", strings.Join(profiles, ", "))
+
+	tracked, err := resolveEntries(entries, m.dir, m.home)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveModes(tracked, opts.Mode); err != nil {
+		return nil, err
+	}
+
+	results, err := m.linkAll(tracked, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.runHooks(HookPostApply, createdTargets(results))
+	return &ApplyResult{Profiles: profiles, Results: results}, nil
+}
+
+// createdTargets lists the targets that were deployed anew
+func createdTargets(results []LinkResult) []string {
+	var targets []string
+	for _, r := range results {
+		if r.Outcome == LinkCreated {
+			targets = append(targets, r.Dotfile.Target)
+		}
+	}
+	return targets
+}
+
+// linkAll applies every dotfile, loading the template data once if needed.
+// The on_change command of every dotfile deployed anew runs right after it.
+func (m *Manager) linkAll(dotfiles []Dotfile, opts LinkOptions) ([]LinkResult, error) {
+	var data *templateData
+	for _, df := range dotfiles {
+		if df.IsTemplate() {
+			var err error
+			if data, err = m.loadTemplateData(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	results := make([]LinkResult, 0, len(dotfiles))
+	for _, df := range dotfiles {
+		outcome, err := m.applyDotfile(df, data, opts)
+		if err != nil && outcome != LinkConflict {
+			outcome = LinkFailed
+		}
+		if outcome == LinkCreated {
+			m.runOnChange(df)
+		}
+		results = append(results, LinkResult{Dotfile: df, Outcome: outcome, Err: err})
+	}
+	return results, nil
+}
+
+// applyDotfile links a single dotfile, copies it in copy or hardlink mode, or
+// renders or decrypts it when it is generated. Something already in place is
+// not an error. Anything else at the target is a conflict, handled according
+// to opts.OnConflict. Skipped conflicts are reported together with a
+// *ConflictError explaining them.
+func (m *Manager) applyDotfile(df Dotfile, data *templateData, opts LinkOptions) (LinkOutcome, error) {
+	src, target := df.Source, df.Target
+	generated := df.IsTemplate() || df.IsEncrypted()
+
+	if _, err := os.Stat(src); err != nil {
+		return 0, fmt.Errorf("source does not exist: %s", src)
+	}
+
+	if info, err := os.Lstat(target); err == nil {
+		conflict := ""
+		switch {
+		case generated:
+			if !info.Mode().IsRegular() {
+				conflict = "target exists and is not a regular file"
+			}
+		case df.IsCopied():
+			upToDate, reason, err := m.copyConflict(df, info)
+			if err != nil {
+				return 0, err
+			}
+			if upToDate {
+				m.log.Infof("✓ Up to date: %s", target)
+				return LinkUnchanged, nil
+			}
+			conflict = reason
+		case info.Mode()&os.ModeSymlink == 0:
+			conflict = "target exists and is not a symlink"
+		default:
+			link, err := os.Readlink(target)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read symlink: %w", err)
+			}
+			if ResolveLink(target, link) != src {
+				conflict = "target already points to " + link
+			} else if want := linkContent(src, target, opts.Relative); opts.Relative && link != want {
+				// Our own link, only its form changes
+				if err := m.replaceLink(want, target); err != nil {
+					return 0, err
+				}
+				m.logf("Relinked %s -> %s", target, want)
+				return LinkCreated, nil
+			} else {
+				m.log.Infof("✓ Already linked: %s -> %s", target, src)
+				return LinkUnchanged, nil
+			}
+		}
+
+		if conflict != "" {
+			deploy, err := m.resolveConflict(df, opts)
+			if err != nil {
+				return 0, err
+			}
+			if !deploy {
+				return LinkConflict, &ConflictError{Target: target, Reason: conflict}
+			}
+		}
+	}
+
+	if generated {
+		var changed bool
+		var err error
+		verb := "Decrypted"
+		if df.IsEncrypted() {
+			changed, err = m.deployEncrypted(df)
+		} else {
+			verb = "Rendered"
+			changed, err = m.deployTemplate(df, data)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !changed {
+			m.log.Infof("✓ Up to date: %s", target)
+			return LinkUnchanged, nil
+		}
+		m.logf("%s %s -> %s", verb, src, target)
+		return LinkCreated, nil
+	}
+
+	if df.IsCopied() {
+		if err := m.deployCopy(df); err != nil {
+			return 0, err
+		}
+		if df.Mode == ModeCopy {
+			m.logf("Copied %s -> %s", src, target)
+		} else {
+			m.logf("Hard linked %s -> %s", target, src)
+		}
+		return LinkCreated, nil
+	}
+
+	// Create parent directory if needed
+	if err := m.mkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if err := m.symlink(linkContent(src, target, opts.Relative), target); err != nil {
+		return 0, fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := m.forgetDeployment(target); err != nil {
+		return 0, err
+	}
+
+	m.logf("Linked %s -> %s", target, src)
+	return LinkCreated, nil
+}
+
+// replaceLink atomically replaces the symlink at path with one holding link
+func (m *Manager) replaceLink(link, path string) error {
+	tmp := path + ".dots-link"
+	if err := m.symlink(link, tmp); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := m.rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace symlink: %w", err)
+	}
+	return nil
+}
+
+// selectDotfiles resolves the dotfiles named by the caller. Names
+// containing glob characters are matched against the paths of all tracked
+// dotfiles relative to the dots directory and against their base names.
+func (m *Manager) selectDotfiles(names []string, all bool) ([]Dotfile, error) {
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return tracked, nil
+	}
+
+	var selected []Dotfile
+	seen := map[string]bool{}
+	add := func(df Dotfile) {
+		if !seen[df.Source] {
+			seen[df.Source] = true
+			selected = append(selected, df)
+		}
+	}
+
+	for _, name := range names {
+		if strings.ContainsAny(name, "*?[") {
+			matched := false
+			for _, df := range tracked {
+				relPath, _ := filepath.Rel(m.dir, df.Source)
+				okRel, err := filepath.Match(name, relPath)
+				if err != nil {
+					return nil, fmt.Errorf("invalid pattern %q: %w", name, err)
+				}
+				okBase, _ := filepath.Match(name, filepath.Base(df.Source))
+				if okRel || okBase {
+					matched = true
+					add(df)
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("no tracked dotfiles match '%s'", name)
+			}
+			continue
+		}
+
+		// Find the dotfile in dots directory
+		df, err := m.Find(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// The files of an unfolded directory are linked one by one
+		expanded := false
+		if info, err := os.Stat(df.Source); err == nil && info.IsDir() && df.Entry == nil {
+			for _, t := range tracked {
+				if isWithin(t.Source, df.Source) {
+					expanded = true
+					add(t)
+				}
+			}
+		}
+		if !expanded {
+			add(*df)
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,170 @@
+// Package dots manages dotfiles kept in a git repository, the dots
+// directory, and deploys them into a home directory as symlinks, copies, hard
+// links, rendered templates or decrypted secrets.
+//
+// A Manager carries out every operation of the dots command. It never writes
+// to stdout: progress goes to its Logger, outcomes are returned as results
+// and failures as errors, several of which can be matched with errors.Is or
+// errors.As.
+package dots
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Logger receives progress messages from a Manager. Messages are single
+// lines without a trailing newline.
+type Logger interface {
+	// Infof reports progress, such as a link that was created
+	Infof(format string, args ...any)
+	// Warnf reports something worth a look that does not fail the operation
+	Warnf(format string, args ...any)
+	// Planf reports a change that was skipped in dry-run mode
+	Planf(format string, args ...any)
+}
+
+// Options configure a Manager. The zero value behaves like the dots command
+// without flags.
+type Options struct {
+	// Home is the directory dotfiles are deployed to, by default the home
+	// directory of the current user
+	Home string
+	// Dir is the dots directory, by default $DOTS_DIR, $XDG_CONFIG_HOME/dots
+	// or ~/.config/dots
+	Dir string
+	// StateDir holds machine-local bookkeeping such as the backup store, by
+	// default $XDG_STATE_HOME/dots or ~/.local/state/dots
+	StateDir string
+	// Profiles selects the profiles of dots.yaml to use, by default
+	// $DOTS_PROFILE or the profiles matching this machine
+	Profiles []string
+	// Git picks the git backend, "exec" or "builtin", by default $DOTS_GIT
+	// or exec when git is installed
+	Git string
+	// DryRun reports every change to Logger.Planf instead of making it
+	DryRun bool
+	// Logger receives progress, by default it is discarded
+	Logger Logger
+	// GitOutput receives the progress of clone, push and pull, by default it
+	// is discarded
+	GitOutput io.Writer
+	// NoHooks skips the hooks of dots.yaml and the hooks directory
+	NoHooks bool
+	// HookOutput receives the output of hooks, by default it is discarded
+	HookOutput io.Writer
+	// Passphrase is asked for the passphrase of encrypted dotfiles when
+	// DOTS_PASSPHRASE is not set. Confirm asks for it to be typed twice.
+	Passphrase func(confirm bool) ([]byte, error)
+}
+
+// Manager carries out dots operations on one dots directory and home
+// directory. It is not safe for concurrent use.
+type Manager struct {
+	opts     Options
+	home     string
+	dir      string
+	stateDir string
+	log      Logger
+
+	// Loaded on first use, see loadDeployments and passphrase
+	deployments map[string]deployment
+	secret      []byte
+}
+
+// New returns a Manager for the given options, filling in the defaults
+func New(opts Options) (*Manager, error) {
+	m := &Manager{opts: opts, home: opts.Home, log: opts.Logger}
+
+	if m.home == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find home directory: %w", err)
+		}
+		m.home = home
+	}
+	if m.log == nil {
+		m.log = discardLogger{}
+	}
+	if m.opts.GitOutput == nil {
+		m.opts.GitOutput = io.Discard
+	}
+	if m.opts.HookOutput == nil {
+		m.opts.HookOutput = io.Discard
+	}
+
+	m.dir = opts.Dir
+	if m.dir == "" {
+		m.dir = os.Getenv("DOTS_DIR")
+	}
+	if m.dir == "" {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			m.dir = filepath.Join(xdg, "dots")
+		}
+	}
+	if m.dir == "" {
+		m.dir = filepath.Join(m.home, ".config", "dots")
+	}
+	dir, err := filepath.Abs(expandHome(m.dir, m.home))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve dots directory: %w", err)
+	}
+	m.dir = dir
+
+	m.stateDir = opts.StateDir
+	if m.stateDir == "" {
+		if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+			m.stateDir = filepath.Join(xdg, "dots")
+		} else {
+			m.stateDir = filepath.Join(m.home, ".local", "state", "dots")
+		}
+	}
+	return m, nil
+}
+
+// Dir returns the dots directory
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Home returns the directory dotfiles are deployed to
+func (m *Manager) Home() string {
+	return m.home
+}
+
+// StateDir returns the directory holding the machine-local state
+func (m *Manager) StateDir() string {
+	return m.stateDir
+}
+
+// DryRun reports whether changes are only planned
+func (m *Manager) DryRun() bool {
+	return m.opts.DryRun
+}
+
+// checkDir verifies that the dots directory exists
+func (m *Manager) checkDir() error {
+	if _, err := os.Stat(m.dir); os.IsNotExist(err) {
+		return fmt.Errorf("%w. Run 'dots init' first", ErrNoDotsDir)
+	}
+	return nil
+}
+
+// checkRepo verifies that the dots directory exists and is a git repository
+func (m *Manager) checkRepo() error {
+	if err := m.checkDir(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, ".git")); os.IsNotExist(err) {
+		return fmt.Errorf("%w. Run 'dots init' to initialize", ErrNotRepo)
+	}
+	return nil
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Infof(string, ...any) {}
+func (discardLogger) Warnf(string, ...any) {}
+func (discardLogger) Planf(string, ...any) {}
@@ -0,0 +1,46 @@
+package dots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestManager returns a Manager whose home, dots and state directories
+// live in a temporary directory. The dots directory is not created.
+func newTestManager(t *testing.T, opts Options) *Manager {
+	t.Helper()
+	root := t.TempDir()
+	opts.Home = filepath.Join(root, "home")
+	opts.Dir = filepath.Join(root, "home", ".config", "dots")
+	opts.StateDir = filepath.Join(root, "state")
+	if err := os.MkdirAll(opts.Home, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// writeTestFile writes content to path, creating its parents
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFile returns the content of path
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,437 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestName is the manifest file kept at the root of the dots directory
+const manifestName = "dots.yaml"
+
+// Manifest describes the dotfiles declared in dots.yaml. The top-level
+// dotfiles form the default profile and apply on every machine. Folded lists
+// the directories of the dots directory that are linked as a whole rather
+// than file by file. Hooks maps hook points such as post-pull to the
+// commands run there, HookTimeout bounds hooks without a timeout of their own.
+type Manifest struct {
+	Dotfiles    []Entry            `yaml:"dotfiles"`
+	Profiles    map[string]Profile `yaml:"profiles"`
+	Folded      []string           `yaml:"folded"`
+	Hooks       map[string][]Hook  `yaml:"hooks"`
+	HookTimeout string             `yaml:"hook_timeout"`
+}
+
+// Entry is a single dotfile declared in the manifest. Source is relative
+// to the dots directory, Target is where the link should be created.
+// Template and encrypted entries are written to the target instead of linked.
+// Mode deploys the entry as a symlink (the default), a copy or a hard link.
+// OnChange runs whenever the content at the target changes.
+type Entry struct {
+	Source    string `yaml:"source"`
+	Target    string `yaml:"target"`
+	Template  bool   `yaml:"template"`
+	Encrypted bool   `yaml:"encrypted"`
+	Mode      string `yaml:"mode"`
+	OnChange  *Hook  `yaml:"on_change"`
+}
+
+// loadManifest reads dots.yaml from the dots directory. A missing manifest
+// is not an error, it simply returns nil.
+func loadManifest(dotsDir string) (*Manifest, error) {
+	path := filepath.Join(dotsDir, manifestName)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", manifestName, err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestName, err)
+	}
+
+	if err := validateEntries(manifest.Dotfiles, "dotfiles"); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(&manifest); err != nil {
+		return nil, err
+	}
+	for _, dir := range manifest.Folded {
+		if !isRepoPath(dir) {
+			return nil, fmt.Errorf("%s: folded directory %q must be inside the dots directory", manifestName, dir)
+		}
+	}
+	for name, profile := range manifest.Profiles {
+		if name == defaultProfile {
+			return nil, fmt.Errorf("%s: profile name %q is reserved for the top-level dotfiles", manifestName, name)
+		}
+		if err := validateEntries(profile.Dotfiles, "profile "+name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &manifest, nil
+}
+
+func validateEntries(entries []Entry, where string) error {
+	for i, entry := range entries {
+		if entry.Source == "" || entry.Target == "" {
+			return fmt.Errorf("%s: %s entry %d needs both source and target", manifestName, where, i+1)
+		}
+		if !isRepoPath(entry.Source) {
+			return fmt.Errorf("%s: source %q must be inside the dots directory", manifestName, entry.Source)
+		}
+		if entry.OnChange != nil {
+			if err := entry.OnChange.validate(); err != nil {
+				return fmt.Errorf("%s: %s entry %d: on_change: %w", manifestName, where, i+1, err)
+			}
+		}
+		if entry.Mode != "" {
+			if _, err := ParseDeployMode(entry.Mode); err != nil {
+				return fmt.Errorf("%s: %s entry %d: %w", manifestName, where, i+1, err)
+			}
+			if entry.Template || entry.Encrypted {
+				return fmt.Errorf("%s: %s entry %d: templates and encrypted files are always written as files, remove its mode", manifestName, where, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// isRepoPath reports whether path is a relative path inside the dots directory
+func isRepoPath(path string) bool {
+	path = filepath.Clean(path)
+	return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, ".."+string(filepath.Separator))
+}
+
+// sourcePath returns the absolute path of the entry inside the dots directory
+func (e Entry) sourcePath(dotsDir string) string {
+	return filepath.Join(dotsDir, filepath.Clean(e.Source))
+}
+
+// targetPath returns the absolute path the entry should be linked to
+func (e Entry) targetPath(home string) (string, error) {
+	target := filepath.Clean(expandHome(e.Target, home))
+	if !filepath.IsAbs(target) {
+		return "", fmt.Errorf("target %q must be an absolute path or start with ~", e.Target)
+	}
+	return target, nil
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// Dotfile is a tracked source in the dots directory together with the path
+// its link lives at
+type Dotfile struct {
+	Source string     // absolute path inside the dots directory
+	Target string     // absolute path of the link
+	Entry  *Entry     // declaring manifest entry, nil when inferred from the layout
+	Mode   DeployMode // how the target is deployed, see resolveMode
+}
+
+// Tracked lists every dotfile dots manages on this machine, see
+// trackedDotfiles
+func (m *Manager) Tracked() ([]Dotfile, error) {
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveModes(tracked, ""); err != nil {
+		return nil, err
+	}
+	return tracked, nil
+}
+
+// trackedDotfiles lists everything dots manages: the entries declared in
+// dots.yaml for the active profiles, followed by the files whose place in the
+// dots directory mirrors their path relative to home. Folded directories are
+// listed as a whole instead of their files, paths excluded by .dotsignore are
+// left out.
+func (m *Manager) trackedDotfiles() ([]Dotfile, error) {
+	return m.trackedIn(m.dir)
+}
+
+// trackedIn lists the dotfiles like trackedDotfiles, for a copy of the dots
+// directory at dotsDir. Sources are within dotsDir, whether a directory is
+// folded is still decided by how its target links to the dots directory.
+func (m *Manager) trackedIn(dotsDir string) ([]Dotfile, error) {
+	home := m.home
+	manifest, err := loadManifest(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := loadIgnore(dotsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracked, declared []Dotfile
+	var folded []string
+	if manifest != nil {
+		folded = manifest.Folded
+		entries, err := manifest.selectedEntries(m.opts.Profiles)
+		if err != nil {
+			return nil, err
+		}
+		if tracked, err = resolveEntries(entries, dotsDir, home); err != nil {
+			return nil, err
+		}
+
+		// Entries of other profiles are not tracked on this machine, but
+		// their sources must not be mistaken for layout-based dotfiles
+		if declared, err = resolveEntries(manifest.allEntries(), dotsDir, home); err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.WalkDir(dotsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dotsDir {
+			return nil
+		}
+
+		// Skip meta files at the root of the dots directory
+		if filepath.Dir(path) == dotsDir && isMetaFile(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Anything under a declared source is covered by its entry
+		for _, df := range declared {
+			if path == df.Source || strings.HasPrefix(path, df.Source+string(filepath.Separator)) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		relPath, err := filepath.Rel(dotsDir, path)
+		if err != nil {
+			return err
+		}
+		if ignore.Excludes(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			target := filepath.Join(home, relPath)
+			if isFolded(folded, relPath, filepath.Join(m.dir, relPath), target) {
+				tracked = append(tracked, Dotfile{Source: path, Target: target})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Generated dotfiles are written to the path without their extension
+		tracked = append(tracked, Dotfile{
+			Source: path,
+			Target: targetName(filepath.Join(home, relPath)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking dots directory: %w", err)
+	}
+
+	return tracked, nil
+}
+
+func resolveEntries(entries []Entry, dotsDir, home string) ([]Dotfile, error) {
+	resolved := make([]Dotfile, 0, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		target, err := entry.targetPath(home)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestName, err)
+		}
+		resolved = append(resolved, Dotfile{
+			Source: entry.sourcePath(dotsDir),
+			Target: target,
+			Entry:  entry,
+		})
+	}
+	return resolved, nil
+}
+
+// lookupDeclared finds a dotfile declared in dots.yaml for the active
+// profiles by its source path or by its target. It returns nil when no
+// entry matches.
+func (m *Manager) lookupDeclared(name string) (*Dotfile, error) {
+	dotsDir, home := m.dir, m.home
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	source := filepath.Join(dotsDir, filepath.Clean(name))
+	target, _ := filepath.Abs(expandHome(name, home))
+	for i, df := range tracked {
+		if df.Entry == nil {
+			continue
+		}
+		if df.Source == source || df.Target == target {
+			return &tracked[i], nil
+		}
+	}
+
+	// Declared for another profile, the layout must not be used instead
+	manifest, err := loadManifest(dotsDir)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+	for _, entry := range manifest.allEntries() {
+		if entry.sourcePath(dotsDir) == source {
+			return nil, fmt.Errorf("'%s' is not part of the active profiles", name)
+		}
+	}
+	return nil, nil
+}
+
+// removeEntries deletes the entries whose source is relPath from dots.yaml,
+// both the top-level ones and those of every profile. Only the lines of the
+// entries are removed, the rest of the file keeps its formatting and
+// comments. It returns how many entries were removed.
+func (m *Manager) removeEntries(relPath string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(m.dir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", manifestName, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", manifestName, err)
+	}
+	if len(doc.Content) == 0 {
+		return 0, nil
+	}
+
+	// The dotfiles lists, top-level and per profile
+	root := doc.Content[0]
+	lists := []*yaml.Node{mappingValue(root, "dotfiles")}
+	if profiles := mappingValue(root, "profiles"); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 1; i < len(profiles.Content); i += 2 {
+			lists = append(lists, mappingValue(profiles.Content[i], "dotfiles"))
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	drop := make([]bool, len(lines))
+	removed := 0
+	for _, list := range lists {
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, item := range list.Content {
+			var entry Entry
+			if err := item.Decode(&entry); err != nil || filepath.Clean(entry.Source) != relPath {
+				continue
+			}
+			if list.Style&yaml.FlowStyle != 0 || item.Line < 1 {
+				return 0, fmt.Errorf("cannot remove the entry of %s from %s, remove it by hand", relPath, manifestName)
+			}
+
+			// An entry runs up to the next one
+			start, limit := item.Line-1, -1
+			if i+1 < len(list.Content) {
+				limit = list.Content[i+1].Line - 1
+			}
+			end := blockEnd(lines, start, limit)
+			for j := start; j < end; j++ {
+				drop[j] = true
+			}
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	var kept []string
+	for i, line := range lines {
+		if !drop[i] {
+			kept = append(kept, line)
+		}
+	}
+	return removed, m.writeManifest(strings.Join(kept, "\n"))
+}
+
+// blockEnd returns the line after the YAML block starting at line start. The
+// block runs up to limit, the line of the node that follows it, or without
+// one, to the first line not indented further than start. Trailing blank
+// lines and comments are left to what follows.
+func blockEnd(lines []string, start, limit int) int {
+	end := limit
+	if end < 0 {
+		indent := len(lines[start]) - len(strings.TrimLeft(lines[start], " \t"))
+		for end = start + 1; end < len(lines); end++ {
+			line := lines[end]
+			trimmed := strings.TrimLeft(line, " \t")
+			if trimmed != "" && len(line)-len(trimmed) <= indent {
+				break
+			}
+		}
+	}
+	for end > start+1 && isBlankOrComment(lines[end-1]) {
+		end--
+	}
+	return end
+}
+
+// writeManifest replaces dots.yaml with content. A manifest left empty is
+// removed rather than kept as a blank file.
+func (m *Manager) writeManifest(content string) error {
+	path := filepath.Join(m.dir, manifestName)
+	if strings.TrimSpace(content) == "" {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return nil
+		}
+		return m.removeAll(path)
+	}
+	_, err := m.writeDeployed(path, []byte(strings.TrimRight(content, "\n")+"\n"), 0o644)
+	return err
+}
+
+// mappingValue returns the value of key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// isBlankOrComment reports whether a line of YAML holds no content
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
@@ -0,0 +1,127 @@
+package dots
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxSubject is how long a generated commit subject may get, longer messages
+// name the applications in the subject and list the details in the body
+const maxSubject = 72
+
+// CommitMessage describes changes for a commit, grouped by the application
+// directory they belong to, such as "nvim: update init.lua; add
+// kitty/theme.conf". Files directly in home are listed on their own.
+func CommitMessage(changes []Change) string {
+	type group struct {
+		app   string
+		verbs []string
+		files map[string][]string
+	}
+
+	var groups []*group
+	var loose *group // files that belong to no application
+	byApp := map[string]*group{}
+	for _, change := range changes {
+		app, file := appPath(change.Path)
+		g := byApp[app]
+		if g == nil {
+			g = &group{app: app, files: map[string][]string{}}
+			byApp[app] = g
+			if app == "" {
+				loose = g
+			} else {
+				groups = append(groups, g)
+			}
+		}
+
+		verb := "update"
+		switch change.Kind {
+		case ChangeAdded, ChangeUntracked:
+			verb = "add"
+		case ChangeDeleted:
+			verb = "remove"
+		case ChangeRenamed:
+			verb = "rename"
+			if change.From != "" {
+				from := change.From
+				if fromApp, fromFile := appPath(from); fromApp == app {
+					from = fromFile
+				}
+				file = from + " to " + file
+			}
+		}
+		if _, ok := g.files[verb]; !ok {
+			g.verbs = append(g.verbs, verb)
+		}
+		g.files[verb] = append(g.files[verb], file)
+	}
+	if loose != nil {
+		groups = append(groups, loose)
+	}
+
+	var lines, apps []string
+	for _, g := range groups {
+		var clauses []string
+		for _, verb := range g.verbs {
+			clauses = append(clauses, verb+" "+strings.Join(g.files[verb], ", "))
+		}
+		line := strings.Join(clauses, "; ")
+		if g.app != "" {
+			line = g.app + ": " + line
+			apps = append(apps, g.app)
+		} else if n := countFiles(g.files); n == 1 {
+			apps = append(apps, g.files[g.verbs[0]][0])
+		} else {
+			apps = append(apps, fmt.Sprintf("%d other files", n))
+		}
+		lines = append(lines, line)
+	}
+
+	subject := strings.Join(lines, "; ")
+	if len(subject) <= maxSubject {
+		return subject
+	}
+
+	subject = "Update " + joinNames(apps)
+	if len(subject) > maxSubject {
+		subject = fmt.Sprintf("Update %d file(s) in %d group(s)", len(changes), len(groups))
+	}
+	return subject + "\n\n- " + strings.Join(lines, "\n- ")
+}
+
+// appPath splits a path in the dots directory into the application it
+// belongs to and the path within it. The application is the first directory,
+// or the one below .config or .local/share, without a leading dot. Files
+// that belong to no application keep their path and get no application.
+func appPath(p string) (string, string) {
+	parts := strings.Split(p, "/")
+	i := 0
+	switch {
+	case len(parts) > 2 && parts[0] == ".config":
+		i = 1
+	case len(parts) > 3 && parts[0] == ".local" && parts[1] == "share":
+		i = 2
+	}
+	if len(parts) <= i+1 {
+		return "", p
+	}
+	return strings.TrimPrefix(parts[i], "."), path.Join(parts[i+1:]...)
+}
+
+// joinNames joins names as in "a, b and c"
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+func countFiles(files map[string][]string) int {
+	n := 0
+	for _, list := range files {
+		n += len(list)
+	}
+	return n
+}
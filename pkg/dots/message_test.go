@@ -0,0 +1,178 @@
+package dots
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []Change
+		want    string
+	}{
+		{
+			name:    "one file",
+			changes: []Change{{Path: ".config/nvim/init.lua", Kind: ChangeModified}},
+			want:    "nvim: update init.lua",
+		},
+		{
+			name: "grouped by application",
+			changes: []Change{
+				{Path: ".config/nvim/init.lua", Kind: ChangeModified},
+				{Path: ".config/kitty/kitty.conf", Kind: ChangeUntracked},
+				{Path: ".config/nvim/lua/a.lua", Kind: ChangeAdded},
+				{Path: ".config/nvim/b.lua", Kind: ChangeModified},
+			},
+			want: "nvim: update init.lua, b.lua; add lua/a.lua; kitty: add kitty.conf",
+		},
+		{
+			name: "top-level and .local/share applications",
+			changes: []Change{
+				{Path: ".vim/vimrc", Kind: ChangeModified},
+				{Path: ".local/share/fonts/mono.ttf", Kind: ChangeDeleted},
+			},
+			want: "vim: update vimrc; fonts: remove mono.ttf",
+		},
+		{
+			name: "files in home come last",
+			changes: []Change{
+				{Path: ".bashrc", Kind: ChangeDeleted},
+				{Path: ".config/git/config", Kind: ChangeModified},
+				{Path: ".zshrc", Kind: ChangeUntracked},
+			},
+			want: "git: update config; remove .bashrc; add .zshrc",
+		},
+		{
+			name:    "rename within an application",
+			changes: []Change{{Path: ".config/nvim/init.lua", From: ".config/nvim/init.vim", Kind: ChangeRenamed}},
+			want:    "nvim: rename init.vim to init.lua",
+		},
+		{
+			name:    "rename into an application",
+			changes: []Change{{Path: ".config/nvim/init.vim", From: ".vimrc", Kind: ChangeRenamed}},
+			want:    "nvim: rename .vimrc to init.vim",
+		},
+		{
+			name:    "rename without its origin",
+			changes: []Change{{Path: ".config/nvim/init.vim", Kind: ChangeRenamed}},
+			want:    "nvim: rename init.vim",
+		},
+		{
+			name: "long subject names the applications",
+			changes: []Change{
+				{Path: ".config/nvim/lua/plugins/completion.lua", Kind: ChangeModified},
+				{Path: ".config/kitty/themes/gruvbox-dark.conf", Kind: ChangeUntracked},
+				{Path: ".gitconfig", Kind: ChangeModified},
+			},
+			want: "Update nvim, kitty and .gitconfig\n\n" +
+				"- nvim: update lua/plugins/completion.lua\n" +
+				"- kitty: add themes/gruvbox-dark.conf\n" +
+				"- update .gitconfig",
+		},
+		{
+			name: "several files in home are counted",
+			changes: []Change{
+				{Path: ".config/nvim/lua/plugins/completion.lua", Kind: ChangeModified},
+				{Path: ".gitconfig", Kind: ChangeModified},
+				{Path: ".bashrc", Kind: ChangeModified},
+				{Path: ".inputrc", Kind: ChangeUntracked},
+			},
+			want: "Update nvim and 3 other files\n\n" +
+				"- nvim: update lua/plugins/completion.lua\n" +
+				"- update .gitconfig, .bashrc; add .inputrc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommitMessage(tt.changes); got != tt.want {
+				t.Errorf("CommitMessage =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitMessageSubjectLength(t *testing.T) {
+	var changes []Change
+	for _, app := range []string{"alacritty", "btop", "fish", "kitty", "lazygit", "nvim", "starship", "tmux", "wezterm", "yazi"} {
+		changes = append(changes, Change{Path: ".config/" + app + "/config.toml", Kind: ChangeModified})
+	}
+
+	message := CommitMessage(changes)
+	subject, body, _ := strings.Cut(message, "\n\n")
+	if subject != "Update 10 file(s) in 10 group(s)" {
+		t.Errorf("subject = %q", subject)
+	}
+	if lines := strings.Split(body, "\n"); len(lines) != 10 || lines[0] != "- alacritty: update config.toml" {
+		t.Errorf("body =\n%s", body)
+	}
+
+	for _, n := range []int{1, 3, 5} {
+		if subject, _, _ := strings.Cut(CommitMessage(changes[:n]), "\n"); len(subject) > maxSubject {
+			t.Errorf("subject for %d change(s) is %d characters: %q", n, len(subject), subject)
+		}
+	}
+}
+
+func TestParsePorcelain(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Change
+	}{
+		{"empty", "", nil},
+		{
+			name:   "kinds",
+			output: " M .bashrc\x00M  .vimrc\x00A  .zshrc\x00 D .inputrc\x00D  .profile\x00?? .config/nvim/init.lua\x00AM .tmux.conf\x00",
+			want: []Change{
+				{Path: ".bashrc", Kind: ChangeModified},
+				{Path: ".vimrc", Kind: ChangeModified},
+				{Path: ".zshrc", Kind: ChangeAdded},
+				{Path: ".inputrc", Kind: ChangeDeleted},
+				{Path: ".profile", Kind: ChangeDeleted},
+				{Path: ".config/nvim/init.lua", Kind: ChangeUntracked},
+				{Path: ".tmux.conf", Kind: ChangeAdded},
+			},
+		},
+		{
+			name:   "rename is followed by its origin",
+			output: "R  .config/nvim/init.lua\x00.config/nvim/init.vim\x00 M .bashrc\x00",
+			want: []Change{
+				{Path: ".config/nvim/init.lua", Kind: ChangeRenamed, From: ".config/nvim/init.vim"},
+				{Path: ".bashrc", Kind: ChangeModified},
+			},
+		},
+		{
+			name:   "renamed and modified",
+			output: "RM new name\x00old name\x00",
+			want:   []Change{{Path: "new name", Kind: ChangeRenamed, From: "old name"}},
+		},
+		{
+			name:   "origin that looks like an entry",
+			output: "R  b\x00?? a\x00 M c\x00",
+			want: []Change{
+				{Path: "b", Kind: ChangeRenamed, From: "?? a"},
+				{Path: "c", Kind: ChangeModified},
+			},
+		},
+		{
+			name:   "special characters are not quoted",
+			output: "?? tab\there\x00?? new\nline\x00?? \"quoted\"\x00",
+			want: []Change{
+				{Path: "tab\there", Kind: ChangeUntracked},
+				{Path: "new\nline", Kind: ChangeUntracked},
+				{Path: "\"quoted\"", Kind: ChangeUntracked},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePorcelain(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePorcelain =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package dots
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DeployMode decides how a dotfile is put in place at its target
+type DeployMode string
+
+const (
+	ModeSymlink  DeployMode = "symlink"  // link the target to the source
+	ModeCopy     DeployMode = "copy"     // copy the source to the target
+	ModeHardlink DeployMode = "hardlink" // hard link the target to the source
+)
+
+// deployedName is the file in the state directory recording the dotfiles
+// deployed as copies or hard links, with the hash of what was deployed
+const deployedName = "deployed.json"
+
+// ParseDeployMode parses symlink, copy or hardlink
+func ParseDeployMode(value string) (DeployMode, error) {
+	switch mode := DeployMode(value); mode {
+	case ModeSymlink, ModeCopy, ModeHardlink:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid mode '%s' (want symlink, copy or hardlink)", value)
+}
+
+// deployment is a copy or hard link dots put in place
+type deployment struct {
+	Source string     `json:"source"`
+	Mode   DeployMode `json:"mode"`
+	Hash   string     `json:"hash"`
+}
+
+// loadDeployments returns the targets mapped to what was deployed there. It
+// is read once per Manager and saved after every change.
+func (m *Manager) loadDeployments() (map[string]deployment, error) {
+	if m.deployments != nil {
+		return m.deployments, nil
+	}
+
+	deployments := map[string]deployment{}
+	data, err := os.ReadFile(filepath.Join(m.stateDir, deployedName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			m.deployments = deployments
+			return deployments, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", deployedName, err)
+	}
+	if err := json.Unmarshal(data, &deployments); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %w", deployedName, err)
+	}
+	m.deployments = deployments
+	return deployments, nil
+}
+
+func (m *Manager) saveDeployments() error {
+	if m.opts.DryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.stateDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m.deployments, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.stateDir, deployedName)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", deployedName, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// recordDeployment remembers that df was deployed with content hash
+func (m *Manager) recordDeployment(df Dotfile, hash string) error {
+	if _, err := m.loadDeployments(); err != nil {
+		return err
+	}
+	m.deployments[df.Target] = deployment{Source: df.Source, Mode: df.Mode, Hash: hash}
+	return m.saveDeployments()
+}
+
+// forgetDeployment drops the record of a target that is a symlink again
+func (m *Manager) forgetDeployment(target string) error {
+	if _, err := m.loadDeployments(); err != nil {
+		return err
+	}
+	if _, ok := m.deployments[target]; !ok {
+		return nil
+	}
+	delete(m.deployments, target)
+	return m.saveDeployments()
+}
+
+// resolveMode fills in how df is deployed. An entry in dots.yaml decides
+// first, then override, then the mode it was last deployed with.
+func (m *Manager) resolveMode(df *Dotfile, override DeployMode) error {
+	switch {
+	case df.Entry != nil && df.Entry.Mode != "":
+		df.Mode = DeployMode(df.Entry.Mode)
+	case override != "":
+		df.Mode = override
+	default:
+		if _, err := m.loadDeployments(); err != nil {
+			return err
+		}
+		df.Mode = ModeSymlink
+		if record, ok := m.deployments[df.Target]; ok && record.Source == df.Source {
+			df.Mode = record.Mode
+		}
+	}
+	return nil
+}
+
+// resolveModes calls resolveMode for every dotfile
+func (m *Manager) resolveModes(dotfiles []Dotfile, override DeployMode) error {
+	for i := range dotfiles {
+		if err := m.resolveMode(&dotfiles[i], override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsCopied reports whether the dotfile is deployed as a copy or hard link
+func (df Dotfile) IsCopied() bool {
+	return df.Mode == ModeCopy || df.Mode == ModeHardlink
+}
+
+// hashFile returns the hex encoded SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// copyConflict inspects the existing target of a copied dotfile. It reports
+// whether the target is already up to date, or why it must not be replaced.
+// A target that still holds what dots deployed last time may be replaced.
+func (m *Manager) copyConflict(df Dotfile, info os.FileInfo) (bool, string, error) {
+	if !info.Mode().IsRegular() {
+		return false, "target exists and is not a regular file", nil
+	}
+
+	if df.Mode == ModeHardlink {
+		srcInfo, err := os.Stat(df.Source)
+		if err != nil {
+			return false, "", err
+		}
+		if os.SameFile(srcInfo, info) {
+			return true, "", m.trackDeployment(df)
+		}
+	}
+
+	targetHash, err := hashFile(df.Target)
+	if err != nil {
+		return false, "", err
+	}
+	sourceHash, err := hashFile(df.Source)
+	if err != nil {
+		return false, "", err
+	}
+	if targetHash == sourceHash {
+		// A hard link that was replaced by a copy still needs relinking
+		if df.Mode == ModeHardlink {
+			return false, "", nil
+		}
+		return true, "", m.trackDeployment(df)
+	}
+
+	if _, err := m.loadDeployments(); err != nil {
+		return false, "", err
+	}
+	record, ok := m.deployments[df.Target]
+	if !ok {
+		return false, "target exists and differs from the source", nil
+	}
+	if record.Hash != targetHash {
+		return false, "target was modified since it was deployed, run 'dots capture' to keep the changes", nil
+	}
+	return false, "", nil
+}
+
+// trackDeployment records a target that already matches its source, so later
+// changes to it can be detected
+func (m *Manager) trackDeployment(df Dotfile) error {
+	if _, err := m.loadDeployments(); err != nil {
+		return err
+	}
+	if record, ok := m.deployments[df.Target]; ok && record.Source == df.Source && record.Mode == df.Mode {
+		return nil
+	}
+	hash, err := hashFile(df.Source)
+	if err != nil {
+		return err
+	}
+	return m.recordDeployment(df, hash)
+}
+
+// deployCopy copies or hard links the source of df to its target, replacing
+// whatever is there
+func (m *Manager) deployCopy(df Dotfile) error {
+	info, err := os.Stat(df.Source)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s mode only supports files, declare the files inside %s separately", df.Mode, df.Source)
+	}
+
+	hash, err := hashFile(df.Source)
+	if err != nil {
+		return err
+	}
+
+	if df.Mode == ModeCopy {
+		content, err := os.ReadFile(df.Source)
+		if err != nil {
+			return err
+		}
+		if _, err := m.writeDeployed(df.Target, content, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return m.recordDeployment(df, hash)
+	}
+
+	if err := m.mkdirAll(filepath.Dir(df.Target), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	// Link next to the target and rename, so it is replaced atomically
+	tmp := df.Target + ".dots-link"
+	if err := m.hardlink(df.Source, tmp); err != nil {
+		return fmt.Errorf("failed to create hard link: %w", err)
+	}
+	if err := m.rename(tmp, df.Target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to create hard link: %w", err)
+	}
+	return m.recordDeployment(df, hash)
+}
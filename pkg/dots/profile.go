@@ -0,0 +1,148 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// defaultProfile names the top-level dotfiles of the manifest
+const defaultProfile = "default"
+
+// Profile is a named set of dotfiles on top of the default profile. It is
+// selected explicitly, see Options.Profiles, or automatically when the machine
+// matches one of its hosts and operating systems.
+type Profile struct {
+	Hosts    []string `yaml:"hosts"`
+	OS       []string `yaml:"os"`
+	Dotfiles []Entry  `yaml:"dotfiles"`
+}
+
+// matches reports whether the profile should be picked automatically on a
+// machine with the given hostname. Profiles without any conditions are only
+// ever selected explicitly.
+func (p Profile) matches(hostname string) bool {
+	if len(p.Hosts) == 0 && len(p.OS) == 0 {
+		return false
+	}
+
+	if len(p.Hosts) > 0 {
+		matched := false
+		for _, pattern := range p.Hosts {
+			if ok, _ := path.Match(pattern, hostname); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(p.OS) > 0 {
+		matched := false
+		for _, goos := range p.OS {
+			if goos == runtime.GOOS {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// activeProfiles returns the profiles selected for this machine, always
+// starting with the default profile. The requested profiles take precedence
+// over the DOTS_PROFILE environment variable, which takes precedence over
+// matching.
+func (m *Manifest) activeProfiles(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		if env := os.Getenv("DOTS_PROFILE"); env != "" {
+			requested = strings.Split(env, ",")
+		}
+	}
+
+	active := []string{defaultProfile}
+
+	if len(requested) > 0 {
+		for _, name := range requested {
+			name = strings.TrimSpace(name)
+			if name == "" || name == defaultProfile {
+				continue
+			}
+			if _, ok := m.Profiles[name]; !ok {
+				return nil, fmt.Errorf("unknown profile %q in %s", name, manifestName)
+			}
+			active = append(active, name)
+		}
+		return active, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine hostname: %w", err)
+	}
+
+	// Sort for a stable order when several profiles match
+	names := make([]string, 0, len(m.Profiles))
+	for name := range m.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if m.Profiles[name].matches(hostname) {
+			active = append(active, name)
+		}
+	}
+	return active, nil
+}
+
+// selectedEntries returns the entries of the active profiles. An entry of a
+// later profile replaces an earlier one with the same target.
+func (m *Manifest) selectedEntries(requested []string) ([]Entry, error) {
+	active, err := m.activeProfiles(requested)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, name := range active {
+		profileEntries := m.Dotfiles
+		if name != defaultProfile {
+			profileEntries = m.Profiles[name].Dotfiles
+		}
+
+		for _, entry := range profileEntries {
+			replaced := false
+			for i := range entries {
+				if filepath.Clean(entries[i].Target) == filepath.Clean(entry.Target) {
+					entries[i] = entry
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// allEntries returns the entries of every profile, active or not
+func (m *Manifest) allEntries() []Entry {
+	entries := append([]Entry{}, m.Dotfiles...)
+	for _, profile := range m.Profiles {
+		entries = append(entries, profile.Dotfiles...)
+	}
+	return entries
+}
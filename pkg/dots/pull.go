@@ -0,0 +1,382 @@
+package dots
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PullStrategy decides how Pull combines incoming commits with local commits
+// that origin does not have yet
+type PullStrategy string
+
+const (
+	PullFastForward PullStrategy = "ff-only" // refuse to pull diverged history
+	PullRebase      PullStrategy = "rebase"  // replay the local commits on top of the incoming ones
+	PullMerge       PullStrategy = "merge"   // join both with a merge commit
+)
+
+// ParsePullStrategy parses ff-only, rebase or merge
+func ParsePullStrategy(value string) (PullStrategy, error) {
+	switch strategy := PullStrategy(value); strategy {
+	case PullFastForward, PullRebase, PullMerge:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid pull strategy '%s' (want ff-only, rebase or merge)", value)
+}
+
+// Resolution settles a conflict of a pull
+type Resolution string
+
+const (
+	ResolveOurs   Resolution = "ours"   // keep the local version
+	ResolveTheirs Resolution = "theirs" // take the incoming version
+	ResolveAbort  Resolution = "abort"  // give up the pull and restore the branch
+)
+
+// ParseResolution parses ours, theirs or abort
+func ParseResolution(value string) (Resolution, error) {
+	switch resolution := Resolution(value); resolution {
+	case ResolveOurs, ResolveTheirs, ResolveAbort:
+		return resolution, nil
+	}
+	return "", fmt.Errorf("invalid resolution '%s' (want ours, theirs or abort)", value)
+}
+
+// PullConflict is a file that the local and the incoming commits both
+// changed in ways git cannot combine
+type PullConflict struct {
+	Path       string     // relative to the dots directory
+	Target     string     // where the dotfile it belongs to is deployed, empty for other files
+	Resolution Resolution // how it was settled
+}
+
+// PullOptions configure Pull
+type PullOptions struct {
+	// Strategy combines diverged history, by default such a pull is refused
+	Strategy PullStrategy
+	// Resolve decides each conflict, without it a conflict aborts the pull
+	Resolve func(conflict PullConflict) Resolution
+	// NoRelink leaves the targets of the dotfiles the incoming commits
+	// added, renamed or deleted alone
+	NoRelink bool
+	// ConfirmRelink is asked before each relink, without it all are made
+	ConfirmRelink func(relink Relink) bool
+}
+
+// PullResult is the outcome of Pull
+type PullResult struct {
+	Upstream string   // the branch pulled from, such as origin/main
+	Incoming []Commit // the commits pulled in, newest first
+	// Changes lists what the incoming commits changed in the dots directory
+	Changes []Change
+	// Conflicts lists the conflicts that were settled
+	Conflicts []PullConflict
+	// Relinks lists what was done, or declined, at the targets of the
+	// dotfiles the incoming commits added, renamed or deleted
+	Relinks []Relink
+	// Stashed reports that uncommitted changes were put aside for the pull
+	Stashed bool
+	// StashErr is why the stashed changes were not brought back, they are
+	// still in the stash
+	StashErr error
+	// Changed holds the targets of the dotfiles the pull added, changed or
+	// removed. It is only filled in when a post-pull hook or on_change
+	// command would use it.
+	Changed []string
+}
+
+// errPullFailed is the StashErr of a pull that failed
+var errPullFailed = errors.New("the pull failed")
+
+// Pull fetches from origin and brings the incoming commits into the dots
+// directory. Before anything is touched, diverged history is refused unless
+// opts.Strategy combines it, and so are uncommitted changes to files the
+// incoming commits change. Other uncommitted changes are stashed for the
+// pull and only brought back when it succeeds. Afterwards new dotfiles are
+// linked, the links of renamed ones moved and those of deleted ones removed,
+// see Relink. A dry run plans them from the upstream tree as last fetched.
+// The pre-pull hooks run first, the on_change commands of the dotfiles the
+// pull changed and the post-pull hooks run last.
+func (m *Manager) Pull(opts PullOptions) (*PullResult, error) {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = PullFastForward
+	}
+
+	if err := m.checkRepo(); err != nil {
+		return nil, err
+	}
+
+	repo, err := m.Repo()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if remote is configured
+	if _, err := repo.RemoteURL(); err != nil {
+		return nil, fmt.Errorf("%w\nAdd a remote with: cd %s && git remote add origin <url>", ErrNoRemote, m.dir)
+	}
+
+	if err := m.runHooks(HookPrePull, nil); err != nil {
+		return nil, err
+	}
+
+	m.log.Infof("Fetching from remote...")
+	if err := repo.Fetch(); err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+
+	upstream, err := repo.Upstream()
+	if err != nil {
+		return nil, fmt.Errorf("current branch has no upstream, push it with 'git push -u origin <branch>' first: %w", err)
+	}
+	result := &PullResult{Upstream: upstream}
+
+	if result.Incoming, err = repo.Log("HEAD", upstream); err != nil {
+		return nil, fmt.Errorf("failed to list incoming commits: %w", err)
+	}
+	if len(result.Incoming) == 0 {
+		m.log.Infof("Already up to date with %s", upstream)
+		m.runHooks(HookPostPull, nil)
+		return result, nil
+	}
+
+	outgoing, err := repo.Log(upstream, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outgoing commits: %w", err)
+	}
+	if len(outgoing) > 0 && strategy == PullFastForward {
+		return nil, fmt.Errorf("%w: %d local and %d incoming commit(s)\nSee them with 'dots diff --remote', then pull with --strategy=rebase or --strategy=merge", ErrDiverged, len(outgoing), len(result.Incoming))
+	}
+
+	base, err := repo.MergeBase("HEAD", upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the merge-base with %s: %w", upstream, err)
+	}
+	if result.Changes, err = repo.Changes(base, upstream); err != nil {
+		return nil, fmt.Errorf("failed to list incoming changes: %w", err)
+	}
+
+	// Check for uncommitted changes
+	changes, err := repo.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %w", err)
+	}
+	if overlap := overlappingPaths(changes, result.Changes); len(overlap) > 0 {
+		return nil, fmt.Errorf("%w to %s, which the incoming commits change too\nCommit them with 'dots sync' and pull again, or discard them", ErrUncommitted, strings.Join(overlap, ", "))
+	}
+
+	if len(changes) > 0 {
+		m.log.Warnf("You have uncommitted changes, stashing them before pull...")
+
+		// Stash changes
+		if err := repo.Stash("Auto-stash before pull"); err != nil {
+			return nil, fmt.Errorf("failed to stash changes: %w", err)
+		}
+		result.Stashed = true
+		m.logf("✓ Changes stashed")
+	}
+
+	// Only what the incoming commits change counts, so compare before the
+	// stashed changes come back
+	var before map[string]string
+	var changed []Dotfile
+	var tracked []Dotfile
+	var relinks []Relink
+	wanted, err := m.wantsPullChanges()
+	if err == nil && wanted && !m.opts.DryRun {
+		before, err = m.snapshotSources()
+	}
+	if err == nil && !opts.NoRelink {
+		tracked, err = m.trackedDotfiles()
+	}
+
+	if err == nil {
+		m.log.Infof("Pulling %d commit(s) from %s...", len(result.Incoming), upstream)
+		err = m.merge(repo, upstream, strategy, opts.Resolve, result)
+		if err == nil && before != nil {
+			result.Changed, changed, err = m.changedSince(before)
+		}
+	}
+	if err == nil && !opts.NoRelink {
+		// A dry run leaves the tree as it was, the upstream tree shows what
+		// the pull would bring
+		var after []Dotfile
+		var relinkErr error
+		if m.opts.DryRun {
+			after, relinkErr = m.trackedAt(repo, upstream)
+		} else {
+			after, relinkErr = m.trackedDotfiles()
+		}
+		if relinkErr == nil {
+			relinks = m.planRelinks(tracked, after, result.Changes)
+		} else {
+			// The pull itself succeeded, so only report this
+			m.log.Warnf("Cannot follow added, renamed and deleted dotfiles: %v", relinkErr)
+		}
+	}
+	if err != nil {
+		// Keep the stash intact, the tree may not be what it was stashed from
+		if result.Stashed {
+			result.StashErr = errPullFailed
+		}
+		return result, err
+	}
+
+	if result.Stashed {
+		m.log.Infof("Applying stashed changes...")
+		if popErr := repo.StashPop(); popErr != nil {
+			result.StashErr = popErr
+		} else {
+			m.logf("✓ Stashed changes applied")
+		}
+	}
+
+	// Linking runs the on_change commands of the dotfiles it deploys
+	linked := map[string]bool{}
+	for _, r := range relinks {
+		if m.opts.DryRun {
+			m.planRelink(r)
+			result.Relinks = append(result.Relinks, r)
+			continue
+		}
+		if opts.ConfirmRelink != nil && !opts.ConfirmRelink(r) {
+			result.Relinks = append(result.Relinks, r)
+			continue
+		}
+		m.relink(&r)
+		if r.Done && r.Kind != RelinkUnlink {
+			linked[r.Dotfile.Target] = true
+		}
+		result.Relinks = append(result.Relinks, r)
+	}
+
+	for _, df := range changed {
+		if !linked[df.Target] {
+			m.runOnChange(df)
+		}
+	}
+	m.runHooks(HookPostPull, result.Changed)
+	return result, nil
+}
+
+// merge brings upstream into the current branch, settling the conflicts it
+// stops at with resolve. When that fails the branch is restored.
+func (m *Manager) merge(repo Repo, upstream string, strategy PullStrategy, resolve func(PullConflict) Resolution, result *PullResult) error {
+	err := repo.Merge(upstream, strategy)
+	for err != nil {
+		conflicts, conflictsErr := repo.Conflicts()
+		if conflictsErr != nil || len(conflicts) == 0 {
+			if strategy != PullFastForward {
+				m.abortMerge(repo, strategy)
+			}
+			return fmt.Errorf("failed to pull: %w", err)
+		}
+
+		for _, path := range conflicts {
+			conflict := PullConflict{Path: path, Target: m.targetOf(path), Resolution: ResolveAbort}
+			if resolve != nil {
+				conflict.Resolution = resolve(conflict)
+			}
+			if conflict.Resolution == ResolveAbort {
+				m.abortMerge(repo, strategy)
+				return fmt.Errorf("%w in %s, nothing was changed", ErrPullConflict, strings.Join(conflicts, ", "))
+			}
+
+			// Ours is the local version, which git calls theirs during a rebase
+			theirs := (conflict.Resolution == ResolveTheirs) != (strategy == PullRebase)
+			if err := repo.ResolveConflict(path, theirs); err != nil {
+				m.abortMerge(repo, strategy)
+				return fmt.Errorf("failed to resolve %s: %w", path, err)
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+			m.logf("✓ Resolved %s with the %s version", path, conflict.Resolution)
+		}
+		err = repo.Continue(strategy)
+	}
+	return nil
+}
+
+// abortMerge restores the branch after a merge or rebase stopped
+func (m *Manager) abortMerge(repo Repo, strategy PullStrategy) {
+	if err := repo.Abort(strategy); err != nil {
+		m.log.Warnf("Failed to abort the %s, finish or abort it with git in %s: %v", strategy, m.dir, err)
+	}
+}
+
+// targetOf returns where the file at path in the dots directory is deployed,
+// or the empty string when it belongs to no tracked dotfile
+func (m *Manager) targetOf(path string) string {
+	tracked, err := m.trackedDotfiles()
+	if err != nil {
+		return ""
+	}
+	source := filepath.Join(m.dir, filepath.FromSlash(path))
+	for _, df := range tracked {
+		if source == df.Source {
+			return df.Target
+		}
+		if isWithin(source, df.Source) {
+			rel, _ := filepath.Rel(df.Source, source)
+			return filepath.Join(df.Target, rel)
+		}
+	}
+	return ""
+}
+
+// overlappingPaths returns the local changes to paths the incoming changes
+// touch as well
+func overlappingPaths(local, incoming []Change) []string {
+	touched := map[string]bool{}
+	for _, change := range incoming {
+		touched[change.Path] = true
+		if change.From != "" {
+			touched[change.From] = true
+		}
+	}
+
+	var overlap []string
+	for _, change := range local {
+		if touched[change.Path] || (change.From != "" && touched[change.From]) {
+			overlap = append(overlap, change.Path)
+		}
+	}
+	return overlap
+}
+
+// mergeArgs returns the git command Merge runs for strategy
+func mergeArgs(rev string, strategy PullStrategy) []string {
+	switch strategy {
+	case PullRebase:
+		return []string{"rebase", rev}
+	case PullMerge:
+		return []string{"merge", "--no-edit", rev}
+	}
+	return []string{"merge", "--ff-only", rev}
+}
+
+// continueArgs returns the git command Continue runs for strategy
+func continueArgs(strategy PullStrategy) []string {
+	if strategy == PullRebase {
+		return []string{"rebase", "--continue"}
+	}
+	return []string{"commit", "--no-edit"}
+}
+
+// abortArgs returns the git command Abort runs for strategy
+func abortArgs(strategy PullStrategy) []string {
+	if strategy == PullRebase {
+		return []string{"rebase", "--abort"}
+	}
+	return []string{"merge", "--abort"}
+}
+
+// conflictSide returns the git checkout flag for one side of a conflict
+func conflictSide(theirs bool) string {
+	if theirs {
+		return "--theirs"
+	}
+	return "--ours"
+}
@@ -0,0 +1,242 @@
+package dots
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newPullManager returns a Manager on the exec backend whose dots directory
+// is a clone of a new remote holding files
+func newPullManager(t *testing.T, files map[string]string) (*Manager, string) {
+	t.Helper()
+	setupGit(t)
+	remote := newRemote(t, files)
+	m := newTestManager(t, Options{Git: "exec"})
+	runGit(t, m.home, "clone", "--quiet", remote, m.dir)
+	return m, remote
+}
+
+// inProgress reports whether a merge or rebase is left unfinished in dir
+func inProgress(dir string) bool {
+	for _, name := range []string{"MERGE_HEAD", "rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(dir, ".git", name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPullResolveConflict(t *testing.T) {
+	tests := []struct {
+		strategy   PullStrategy
+		resolution Resolution
+		want       string
+	}{
+		{PullMerge, ResolveOurs, "local\n"},
+		{PullMerge, ResolveTheirs, "remote\n"},
+		// During a rebase git calls the local commit theirs
+		{PullRebase, ResolveOurs, "local\n"},
+		{PullRebase, ResolveTheirs, "remote\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy)+"/"+string(tt.resolution), func(t *testing.T) {
+			m, remote := newPullManager(t, map[string]string{".bashrc": "base\n", ".vimrc": "vim\n"})
+			commitFiles(t, m.dir, "Local change", map[string]string{".bashrc": "local\n"})
+			upstream := pushFrom(t, remote, "Remote change", map[string]string{".bashrc": "remote\n", ".zshrc": "zsh\n"})
+
+			var asked []PullConflict
+			result, err := m.Pull(PullOptions{
+				Strategy: tt.strategy,
+				Resolve: func(conflict PullConflict) Resolution {
+					asked = append(asked, conflict)
+					return tt.resolution
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(asked) != 1 || asked[0].Path != ".bashrc" || asked[0].Target != filepath.Join(m.home, ".bashrc") {
+				t.Errorf("asked about %+v, want .bashrc deployed in home", asked)
+			}
+			if len(result.Conflicts) != 1 || result.Conflicts[0].Resolution != tt.resolution {
+				t.Errorf("result.Conflicts = %+v", result.Conflicts)
+			}
+			if got := readTestFile(t, filepath.Join(m.dir, ".bashrc")); got != tt.want {
+				t.Errorf(".bashrc holds %q, want %q", got, tt.want)
+			}
+			if got := readTestFile(t, filepath.Join(m.dir, ".zshrc")); got != "zsh\n" {
+				t.Errorf("the incoming .zshrc holds %q", got)
+			}
+			if inProgress(m.dir) {
+				t.Error("the merge or rebase was left unfinished")
+			}
+			if status := runGit(t, m.dir, "status", "--porcelain"); status != "" {
+				t.Errorf("uncommitted changes after the pull:\n%s", status)
+			}
+			if runGit(t, m.dir, "merge-base", "--is-ancestor", upstream, "HEAD") != "" {
+				t.Error("the incoming commit is not part of the branch")
+			}
+
+			// Resolving a rebase with the incoming version empties the local
+			// commit, which is skipped
+			if tt.strategy == PullRebase && tt.resolution == ResolveTheirs {
+				if head := runGit(t, m.dir, "rev-parse", "HEAD"); head != upstream {
+					t.Errorf("HEAD is %s, want the emptied local commit skipped", head)
+				}
+			}
+		})
+	}
+}
+
+func TestPullResolveDeleted(t *testing.T) {
+	tests := []struct {
+		strategy   PullStrategy
+		resolution Resolution
+		want       string // empty when the file is deleted
+	}{
+		{PullMerge, ResolveOurs, ""},
+		{PullMerge, ResolveTheirs, "remote\n"},
+		{PullRebase, ResolveOurs, ""},
+		{PullRebase, ResolveTheirs, "remote\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy)+"/"+string(tt.resolution), func(t *testing.T) {
+			m, remote := newPullManager(t, map[string]string{".bashrc": "base\n", ".vimrc": "vim\n"})
+			commitFiles(t, m.dir, "Remove bashrc", map[string]string{".bashrc": ""})
+			pushFrom(t, remote, "Change bashrc", map[string]string{".bashrc": "remote\n"})
+
+			_, err := m.Pull(PullOptions{
+				Strategy: tt.strategy,
+				Resolve:  func(PullConflict) Resolution { return tt.resolution },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(m.dir, ".bashrc")
+			if tt.want == "" {
+				if _, err := os.Lstat(path); !os.IsNotExist(err) {
+					t.Errorf(".bashrc was kept: %v", err)
+				}
+			} else if got := readTestFile(t, path); got != tt.want {
+				t.Errorf(".bashrc holds %q, want %q", got, tt.want)
+			}
+			if inProgress(m.dir) {
+				t.Error("the merge or rebase was left unfinished")
+			}
+			if status := runGit(t, m.dir, "status", "--porcelain"); status != "" {
+				t.Errorf("uncommitted changes after the pull:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestPullAbortKeepsStash(t *testing.T) {
+	for _, strategy := range []PullStrategy{PullMerge, PullRebase} {
+		t.Run(string(strategy), func(t *testing.T) {
+			m, remote := newPullManager(t, map[string]string{".bashrc": "base\n", ".vimrc": "vim\n"})
+			local := commitFiles(t, m.dir, "Local change", map[string]string{".bashrc": "local\n"})
+			pushFrom(t, remote, "Remote change", map[string]string{".bashrc": "remote\n"})
+
+			// Uncommitted changes the incoming commits do not touch
+			writeTestFile(t, filepath.Join(m.dir, ".vimrc"), "vim changed\n")
+			writeTestFile(t, filepath.Join(m.dir, ".inputrc"), "untracked\n")
+
+			result, err := m.Pull(PullOptions{
+				Strategy: strategy,
+				Resolve:  func(PullConflict) Resolution { return ResolveAbort },
+			})
+			if !errors.Is(err, ErrPullConflict) {
+				t.Fatalf("Pull = %v, want ErrPullConflict", err)
+			}
+			if result == nil || !result.Stashed || !errors.Is(result.StashErr, errPullFailed) {
+				t.Fatalf("result = %+v, want the changes kept in the stash", result)
+			}
+
+			if head := runGit(t, m.dir, "rev-parse", "HEAD"); head != local {
+				t.Errorf("HEAD moved to %s", head)
+			}
+			if inProgress(m.dir) {
+				t.Error("the merge or rebase was not aborted")
+			}
+			if got := readTestFile(t, filepath.Join(m.dir, ".bashrc")); got != "local\n" {
+				t.Errorf(".bashrc holds %q after abort", got)
+			}
+			if stashes := runGit(t, m.dir, "stash", "list"); len(strings.Split(stashes, "\n")) != 1 || stashes == "" {
+				t.Fatalf("stash list:\n%s", stashes)
+			}
+
+			runGit(t, m.dir, "stash", "pop")
+			if got := readTestFile(t, filepath.Join(m.dir, ".vimrc")); got != "vim changed\n" {
+				t.Errorf("the stashed .vimrc holds %q", got)
+			}
+			if got := readTestFile(t, filepath.Join(m.dir, ".inputrc")); got != "untracked\n" {
+				t.Errorf("the stashed untracked .inputrc holds %q", got)
+			}
+		})
+	}
+}
+
+func TestPullRestoresStash(t *testing.T) {
+	m, remote := newPullManager(t, map[string]string{".bashrc": "base\n", ".vimrc": "vim\n"})
+	upstream := pushFrom(t, remote, "Remote change", map[string]string{".bashrc": "remote\n"})
+	writeTestFile(t, filepath.Join(m.dir, ".vimrc"), "vim changed\n")
+	writeTestFile(t, filepath.Join(m.dir, ".inputrc"), "untracked\n")
+
+	result, err := m.Pull(PullOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Stashed || result.StashErr != nil {
+		t.Errorf("Stashed = %v, StashErr = %v", result.Stashed, result.StashErr)
+	}
+	if head := runGit(t, m.dir, "rev-parse", "HEAD"); head != upstream {
+		t.Errorf("HEAD is %s, want %s", head, upstream)
+	}
+	if got := readTestFile(t, filepath.Join(m.dir, ".vimrc")); got != "vim changed\n" {
+		t.Errorf(".vimrc holds %q, want the uncommitted change back", got)
+	}
+	if got := readTestFile(t, filepath.Join(m.dir, ".inputrc")); got != "untracked\n" {
+		t.Errorf(".inputrc holds %q, want the untracked file back", got)
+	}
+	if stashes := runGit(t, m.dir, "stash", "list"); stashes != "" {
+		t.Errorf("stash left behind:\n%s", stashes)
+	}
+}
+
+func TestPullRefuses(t *testing.T) {
+	t.Run("diverged", func(t *testing.T) {
+		m, remote := newPullManager(t, map[string]string{".bashrc": "base\n"})
+		local := commitFiles(t, m.dir, "Local change", map[string]string{".vimrc": "vim\n"})
+		pushFrom(t, remote, "Remote change", map[string]string{".zshrc": "zsh\n"})
+
+		if _, err := m.Pull(PullOptions{}); !errors.Is(err, ErrDiverged) {
+			t.Fatalf("Pull = %v, want ErrDiverged", err)
+		}
+		if head := runGit(t, m.dir, "rev-parse", "HEAD"); head != local {
+			t.Errorf("HEAD moved to %s", head)
+		}
+	})
+
+	t.Run("overlapping changes", func(t *testing.T) {
+		m, remote := newPullManager(t, map[string]string{".bashrc": "base\n"})
+		pushFrom(t, remote, "Remote change", map[string]string{".bashrc": "remote\n"})
+		writeTestFile(t, filepath.Join(m.dir, ".bashrc"), "uncommitted\n")
+
+		if _, err := m.Pull(PullOptions{}); !errors.Is(err, ErrUncommitted) {
+			t.Fatalf("Pull = %v, want ErrUncommitted", err)
+		}
+		if got := readTestFile(t, filepath.Join(m.dir, ".bashrc")); got != "uncommitted\n" {
+			t.Errorf(".bashrc holds %q", got)
+		}
+		if stashes := runGit(t, m.dir, "stash", "list"); stashes != "" {
+			t.Errorf("changes were stashed:\n%s", stashes)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RelinkKind is how Pull follows a dotfile the incoming commits added,
+// renamed or deleted
+type RelinkKind string
+
+const (
+	RelinkLink   RelinkKind = "link"   // deploy a new dotfile
+	RelinkMove   RelinkKind = "move"   // move the link of a renamed dotfile to its new target
+	RelinkUnlink RelinkKind = "unlink" // remove the link a deleted dotfile left dangling
+)
+
+// Relink is a change Pull makes at the targets so they follow the dotfiles
+// the incoming commits added, renamed or deleted
+type Relink struct {
+	Kind    RelinkKind
+	Dotfile Dotfile  // the new dotfile, or the deleted one for RelinkUnlink
+	From    *Dotfile // the dotfile before it was renamed, for RelinkMove
+	Done    bool     // false when it was declined or failed
+	Err     error    // why it failed, a *ConflictError when something else is at the new target
+}
+
+// trackedAt lists the dotfiles tracked at revision rev, as if it was checked
+// out in the dots directory
+func (m *Manager) trackedAt(repo Repo, rev string) ([]Dotfile, error) {
+	tmp, err := os.MkdirTemp("", "dots-tree-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := repo.Export(rev, tmp); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rev, err)
+	}
+	tracked, err := m.trackedIn(tmp)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tracked {
+		rel, _ := filepath.Rel(tmp, tracked[i].Source)
+		tracked[i].Source = filepath.Join(m.dir, rel)
+	}
+	return tracked, nil
+}
+
+// planRelinks compares the dotfiles tracked before the incoming commits
+// with the ones tracked after them. Dotfiles the commits renamed have their
+// links moved, new ones are linked and the links of deleted ones are removed.
+// Copies and generated files of deleted dotfiles stay in place.
+func (m *Manager) planRelinks(before, after []Dotfile, changes []Change) []Relink {
+	key := func(df Dotfile) string { return df.Source + "\x00" + df.Target }
+	inBefore := map[string]bool{}
+	for _, df := range before {
+		inBefore[key(df)] = true
+	}
+	inAfter := map[string]bool{}
+	for _, df := range after {
+		inAfter[key(df)] = true
+	}
+
+	var added []Dotfile
+	for _, df := range after {
+		if !inBefore[key(df)] {
+			added = append(added, df)
+		}
+	}
+
+	renamed := map[string]string{}
+	for _, change := range changes {
+		if change.Kind == ChangeRenamed {
+			renamed[filepath.Join(m.dir, filepath.FromSlash(change.From))] = filepath.Join(m.dir, filepath.FromSlash(change.Path))
+		}
+	}
+
+	var relinks []Relink
+	moved := map[string]bool{}
+	for _, df := range before {
+		if inAfter[key(df)] {
+			continue
+		}
+		old := df
+
+		// A renamed source, or a declared entry that now links elsewhere
+		source := movedSource(old.Source, renamed)
+		found := false
+		for _, candidate := range added {
+			if !moved[key(candidate)] && (candidate.Source == source || candidate.Source == old.Source) {
+				moved[key(candidate)] = true
+				relinks = append(relinks, Relink{Kind: RelinkMove, Dotfile: candidate, From: &old})
+				found = true
+				break
+			}
+		}
+		if !found && linksTo(old) {
+			relinks = append(relinks, Relink{Kind: RelinkUnlink, Dotfile: old})
+		}
+	}
+	for _, df := range added {
+		if !moved[key(df)] {
+			relinks = append(relinks, Relink{Kind: RelinkLink, Dotfile: df})
+		}
+	}
+	return relinks
+}
+
+// movedSource returns where a source was renamed to, or the empty string.
+// A directory counts as renamed when a file in it was.
+func movedSource(source string, renamed map[string]string) string {
+	if to, ok := renamed[source]; ok {
+		return to
+	}
+	for from, to := range renamed {
+		if !isWithin(from, source) {
+			continue
+		}
+		rel, _ := filepath.Rel(source, from)
+		if strings.HasSuffix(to, string(filepath.Separator)+rel) {
+			return strings.TrimSuffix(to, string(filepath.Separator)+rel)
+		}
+	}
+	return ""
+}
+
+// linksTo reports whether the target of df is a symlink to its source
+func linksTo(df Dotfile) bool {
+	info, err := os.Lstat(df.Target)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	link, err := os.Readlink(df.Target)
+	return err == nil && ResolveLink(df.Target, link) == df.Source
+}
+
+// planRelink reports a relink in dry-run mode
+func (m *Manager) planRelink(r Relink) {
+	switch r.Kind {
+	case RelinkLink:
+		m.planf("link new dotfile %s -> %s", r.Dotfile.Target, r.Dotfile.Source)
+	case RelinkMove:
+		m.planf("move link %s to %s -> %s", r.From.Target, r.Dotfile.Target, r.Dotfile.Source)
+	case RelinkUnlink:
+		m.planf("remove dangling link %s", r.Dotfile.Target)
+	}
+}
+
+// relink makes a single relink, removing the old link first
+func (m *Manager) relink(r *Relink) {
+	old := r.From
+	if r.Kind == RelinkUnlink {
+		old = &r.Dotfile
+	}
+	if old != nil && linksTo(*old) {
+		if err := m.removeAll(old.Target); err != nil {
+			r.Err = fmt.Errorf("failed to remove %s: %w", old.Target, err)
+			return
+		}
+		m.logf("Removed link %s", old.Target)
+	}
+	if r.Kind == RelinkUnlink {
+		r.Done = true
+		return
+	}
+
+	dotfiles := []Dotfile{r.Dotfile}
+	if err := m.resolveModes(dotfiles, ""); err != nil {
+		r.Err = err
+		return
+	}
+	results, err := m.linkAll(dotfiles, LinkOptions{OnConflict: ConflictSkip})
+	if err != nil {
+		r.Err = err
+		return
+	}
+	r.Dotfile, r.Err = results[0].Dotfile, results[0].Err
+	r.Done = results[0].Outcome == LinkCreated || results[0].Outcome == LinkUnchanged
+}
@@ -0,0 +1,142 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// recordLogger keeps what a Manager plans
+type recordLogger struct {
+	plans []string
+}
+
+func (l *recordLogger) Infof(string, ...any) {}
+func (l *recordLogger) Warnf(string, ...any) {}
+func (l *recordLogger) Planf(format string, args ...any) {
+	l.plans = append(l.plans, fmt.Sprintf(format, args...))
+}
+
+// setupRelink links .vimrc and .inputrc of a new clone into home, then
+// pushes a commit adding .zshrc, moving .vimrc to .vim/vimrc and deleting
+// .inputrc
+func setupRelink(t *testing.T, opts Options) *Manager {
+	t.Helper()
+	setupGit(t)
+	remote := newRemote(t, map[string]string{".bashrc": "bash\n", ".vimrc": "vim\n", ".inputrc": "input\n"})
+	m := newTestManager(t, opts)
+	runGit(t, m.home, "clone", "--quiet", remote, m.dir)
+	for _, name := range []string{".vimrc", ".inputrc"} {
+		if err := os.Symlink(filepath.Join(m.dir, name), filepath.Join(m.home, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	other := cloneRemote(t, remote)
+	if err := os.MkdirAll(filepath.Join(other, ".vim"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, other, "mv", ".vimrc", ".vim/vimrc")
+	commitFiles(t, other, "Move vimrc, add zshrc, remove inputrc", map[string]string{".zshrc": "zsh\n", ".inputrc": ""})
+	runGit(t, other, "push", "--quiet", "origin", "main")
+	return m
+}
+
+// linkOf returns where the symlink at path points, or the empty string
+func linkOf(path string) string {
+	link, _ := os.Readlink(path)
+	return link
+}
+
+func TestPullRelinks(t *testing.T) {
+	m := setupRelink(t, Options{Git: "exec"})
+
+	result, err := m.Pull(PullOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range result.Relinks {
+		if !r.Done || r.Err != nil {
+			t.Errorf("%s %s: done %v, %v", r.Kind, r.Dotfile.Target, r.Done, r.Err)
+		}
+	}
+
+	if got, want := linkOf(filepath.Join(m.home, ".zshrc")), filepath.Join(m.dir, ".zshrc"); got != want {
+		t.Errorf("~/.zshrc links to %q, want %q", got, want)
+	}
+	if got, want := linkOf(filepath.Join(m.home, ".vim", "vimrc")), filepath.Join(m.dir, ".vim", "vimrc"); got != want {
+		t.Errorf("~/.vim/vimrc links to %q, want %q", got, want)
+	}
+	for _, name := range []string{".vimrc", ".inputrc"} {
+		if _, err := os.Lstat(filepath.Join(m.home, name)); !os.IsNotExist(err) {
+			t.Errorf("the old link ~/%s was kept: %v", name, err)
+		}
+	}
+}
+
+func TestPullDryRunPlansRelinks(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			log := &recordLogger{}
+			m := setupRelink(t, Options{Git: backend.name, DryRun: true, Logger: log})
+			// A dry run does not fetch, it uses what was fetched before
+			runGit(t, m.dir, "fetch", "--quiet", "origin")
+			head := runGit(t, m.dir, "rev-parse", "HEAD")
+
+			result, err := m.Pull(PullOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var kinds []string
+			for _, r := range result.Relinks {
+				kinds = append(kinds, string(r.Kind)+" "+r.Dotfile.Target)
+				if r.Done {
+					t.Errorf("%s %s is reported done in a dry run", r.Kind, r.Dotfile.Target)
+				}
+			}
+			sort.Strings(kinds)
+			want := []string{
+				"link " + filepath.Join(m.home, ".zshrc"),
+				"move " + filepath.Join(m.home, ".vim", "vimrc"),
+				"unlink " + filepath.Join(m.home, ".inputrc"),
+			}
+			if !reflect.DeepEqual(kinds, want) {
+				t.Errorf("relinks =\n%v\nwant\n%v", kinds, want)
+			}
+
+			planned := map[string]bool{}
+			for _, plan := range log.plans {
+				planned[plan] = true
+			}
+			for _, plan := range []string{
+				fmt.Sprintf("link new dotfile %s -> %s", filepath.Join(m.home, ".zshrc"), filepath.Join(m.dir, ".zshrc")),
+				fmt.Sprintf("move link %s to %s -> %s", filepath.Join(m.home, ".vimrc"), filepath.Join(m.home, ".vim", "vimrc"), filepath.Join(m.dir, ".vim", "vimrc")),
+				fmt.Sprintf("remove dangling link %s", filepath.Join(m.home, ".inputrc")),
+			} {
+				if !planned[plan] {
+					t.Errorf("%q was not planned, got:\n%v", plan, log.plans)
+				}
+			}
+
+			// Nothing changed
+			if got := runGit(t, m.dir, "rev-parse", "HEAD"); got != head {
+				t.Errorf("HEAD moved to %s", got)
+			}
+			if got := linkOf(filepath.Join(m.home, ".vimrc")); got != filepath.Join(m.dir, ".vimrc") {
+				t.Errorf("~/.vimrc links to %q", got)
+			}
+			for _, path := range []string{filepath.Join(m.home, ".zshrc"), filepath.Join(m.home, ".vim")} {
+				if _, err := os.Lstat(path); !os.IsNotExist(err) {
+					t.Errorf("%s was created: %v", path, err)
+				}
+			}
+			if status := runGit(t, m.dir, "status", "--porcelain"); status != "" {
+				t.Errorf("the dots directory changed:\n%s", status)
+			}
+		})
+	}
+}
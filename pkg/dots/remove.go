@@ -0,0 +1,158 @@
+package dots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RemoveResult is the outcome of Remove
+type RemoveResult struct {
+	Dotfile  Dotfile
+	Restored bool // the target was a link and holds the file again
+}
+
+// Remove stops tracking a dotfile. Its link is replaced by the file from the
+// dots directory, copies and generated files stay in place as they are. A
+// dotfile declared in dots.yaml has its entry removed as well.
+func (m *Manager) Remove(filename string) (*RemoveResult, error) {
+	// Find the dotfile in dots directory
+	df, err := m.Find(filename)
+	if err != nil {
+		return nil, err
+	}
+	dotsPath, homePath := df.Source, df.Target
+
+	// Check if file exists in dots directory
+	dotsInfo, err := os.Stat(dotsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access dots file: %w", err)
+	}
+
+	// Check if symlink exists
+	deployed, err := m.loadDeployments()
+	if err != nil {
+		return nil, err
+	}
+	record, copied := deployed[homePath]
+	copied = copied && record.Source == dotsPath
+
+	restore := false
+	var unfolded []Dotfile
+	linkInfo, err := os.Lstat(homePath)
+	if copied {
+		// A copy or hard link is a standalone file and stays in place
+		if err == nil {
+			m.log.Infof("Keeping %s: %s", record.Mode, homePath)
+		}
+	} else if isGenerated(dotsPath) {
+		// Rendered or decrypted output is a standalone file and stays in place
+		if err == nil {
+			m.log.Infof("Keeping generated file: %s", homePath)
+		}
+	} else if err != nil {
+		if os.IsNotExist(err) {
+			// Symlink doesn't exist, just remove from dots directory
+			m.log.Warnf("No symlink found at %s, removing from dots directory only", homePath)
+		} else {
+			return nil, fmt.Errorf("failed to check symlink: %w", err)
+		}
+	} else if dotsInfo.IsDir() && linkInfo.IsDir() {
+		// An unfolded directory, every file in it is linked on its own
+		if unfolded, _, err = scanUnfolded(dotsPath, homePath); err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", homePath, err)
+		}
+	} else {
+		// Check if it's actually a symlink
+		if linkInfo.Mode()&os.ModeSymlink == 0 {
+			// Not a symlink, something else exists there
+			return nil, fmt.Errorf("file at %s exists but is not a symlink\nManual intervention required", homePath)
+		}
+
+		// Verify it points to our dots file
+		linkTarget, err := os.Readlink(homePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read symlink: %w", err)
+		}
+
+		if ResolveLink(homePath, linkTarget) != dotsPath {
+			return nil, fmt.Errorf("symlink at %s points to %s, not %s\nManual intervention required",
+				homePath, linkTarget, dotsPath)
+		}
+		restore = true
+	}
+
+	// The tracked copy is deleted, keep it in the backup store
+	if _, err := m.saveBackup(dotsPath, "remove"); err != nil {
+		return nil, err
+	}
+
+	tx, err := m.beginTransaction("remove " + dotsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.run(func() error {
+		if restore {
+			// Remove the symlink
+			if err := tx.remove(homePath); err != nil {
+				return fmt.Errorf("failed to remove symlink: %w", err)
+			}
+			m.logf("✓ Removed symlink: %s", homePath)
+
+			// Copy file/directory back from dots to home
+			if dotsInfo.IsDir() {
+				if err := tx.copy(dotsPath, homePath); err != nil {
+					return fmt.Errorf("failed to restore directory: %w", err)
+				}
+				m.logf("✓ Restored directory: %s", homePath)
+			} else {
+				if err := tx.copy(dotsPath, homePath); err != nil {
+					return fmt.Errorf("failed to restore file: %w", err)
+				}
+				m.logf("✓ Restored file: %s", homePath)
+			}
+		}
+
+		// Replace the links of an unfolded directory with their files
+		for _, link := range unfolded {
+			if err := tx.remove(link.Target); err != nil {
+				return fmt.Errorf("failed to remove symlink: %w", err)
+			}
+			if err := tx.copy(link.Source, link.Target); err != nil {
+				return fmt.Errorf("failed to restore file: %w", err)
+			}
+			m.logf("✓ Restored file: %s", link.Target)
+		}
+
+		// Remove from dots directory
+		if err := tx.remove(dotsPath); err != nil {
+			return fmt.Errorf("failed to remove from dots directory: %w", err)
+		}
+		m.logf("✓ Removed from dots directory: %s", dotsPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if copied {
+		if err := m.forgetDeployment(homePath); err != nil {
+			return nil, err
+		}
+	}
+	relPath, _ := filepath.Rel(m.dir, dotsPath)
+	if df.Entry != nil {
+		// The entry would point at a source that is gone
+		if _, err := m.removeEntries(relPath); err != nil {
+			return nil, fmt.Errorf("%s was removed, but not its entry in %s: %w", relPath, manifestName, err)
+		}
+		m.logf("✓ Removed the entry of %s from %s", relPath, manifestName)
+	} else if dotsInfo.IsDir() {
+		if err := m.setFolded(relPath, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RemoveResult{Dotfile: *df, Restored: restore || len(unfolded) > 0}, nil
+}
@@ -0,0 +1,93 @@
+package dots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveDeclared(t *testing.T) {
+	m := newTestManager(t, Options{})
+	manifest := filepath.Join(m.dir, manifestName)
+	writeTestFile(t, manifest, `# My dotfiles
+dotfiles:
+  - source: gitconfig
+    target: ~/.gitconfig
+    mode: copy
+
+  # Shell
+  - source: zsh/zshrc
+    target: ~/.zshrc
+
+profiles:
+  work:
+    hosts: ["*"]
+    dotfiles:
+      - source: zsh/zshrc
+        target: ~/.zshrc
+      - source: work/npmrc
+        target: ~/.npmrc
+folded:
+  - .config/nvim
+`)
+	source := filepath.Join(m.dir, "zsh", "zshrc")
+	target := filepath.Join(m.home, ".zshrc")
+	writeTestFile(t, source, "export EDITOR=vim\n")
+	writeTestFile(t, filepath.Join(m.dir, "gitconfig"), "[user]\n")
+	writeTestFile(t, filepath.Join(m.dir, "work", "npmrc"), "registry=\n")
+	if err := os.Symlink(source, target); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := m.Remove("zsh/zshrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Restored {
+		t.Error("the target was not restored")
+	}
+	if got := readTestFile(t, target); got != "export EDITOR=vim\n" {
+		t.Errorf("%s holds %q, want the restored file", target, got)
+	}
+	if _, err := os.Lstat(source); !os.IsNotExist(err) {
+		t.Errorf("%s is still in the dots directory: %v", source, err)
+	}
+
+	want := `# My dotfiles
+dotfiles:
+  - source: gitconfig
+    target: ~/.gitconfig
+    mode: copy
+
+  # Shell
+
+profiles:
+  work:
+    hosts: ["*"]
+    dotfiles:
+      - source: work/npmrc
+        target: ~/.npmrc
+folded:
+  - .config/nvim
+`
+	if got := readTestFile(t, manifest); got != want {
+		t.Errorf("%s after remove:\n%s\nwant:\n%s", manifestName, got, want)
+	}
+	if _, err := m.trackedDotfiles(); err != nil {
+		t.Errorf("the manifest no longer loads: %v", err)
+	}
+}
+
+func TestRemoveLastEntry(t *testing.T) {
+	m := newTestManager(t, Options{})
+	manifest := filepath.Join(m.dir, manifestName)
+	writeTestFile(t, manifest, "dotfiles:\n  - {source: vimrc, target: ~/.vimrc}\n")
+	writeTestFile(t, filepath.Join(m.dir, "vimrc"), "set nu\n")
+
+	if _, err := m.Remove("vimrc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, manifest); got != "dotfiles:\n" {
+		t.Errorf("%s after removing its last entry: %q", manifestName, got)
+	}
+}
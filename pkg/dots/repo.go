@@ -0,0 +1,204 @@
+package dots
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Repo is the git repository in the dots directory. It is backed either by
+// the git command or by a built-in implementation, see Manager.Repo.
+type Repo interface {
+	// Init creates an empty repository
+	Init() error
+	// Clone clones url into the repository directory, which must not exist
+	Clone(url string) error
+	// Status lists the paths that differ from the last commit
+	Status() ([]Change, error)
+	// AddAll stages every change, including new and deleted files
+	AddAll() error
+	// Add stages the changes to the given paths, including deletions. Paths
+	// are relative to the repository, as in Change.
+	Add(paths []string) error
+	// Commit records the staged changes. Given paths, it records only the
+	// changes to them and leaves the rest of the index staged.
+	Commit(message string, paths []string) error
+	// RemoteURL returns the URL of the origin remote
+	RemoteURL() (string, error)
+	// Push pushes the current branch to origin
+	Push() error
+	// Stash puts uncommitted changes aside, untracked files included.
+	// StashPop brings them back and keeps them stashed when that fails.
+	Stash(message string) error
+	StashPop() error
+	// Fetch updates the remote-tracking branches of origin
+	Fetch() error
+	// Upstream returns the branch the current branch pulls from, such as
+	// origin/main
+	Upstream() (string, error)
+	// MergeBase returns the best common ancestor of two revisions
+	MergeBase(a, b string) (string, error)
+	// Log lists the commits reachable from to but not from from, newest first
+	Log(from, to string) ([]Commit, error)
+	// Diff writes the changes from revision from to revision to as a unified
+	// diff. An empty to stands for the working tree, untracked files included.
+	Diff(w io.Writer, from, to string) error
+	// Changes lists the paths that differ between two revisions, with
+	// renames detected
+	Changes(from, to string) ([]Change, error)
+	// Export writes the files of revision rev into dir, leaving the
+	// repository, its index and its working tree alone
+	Export(rev, dir string) error
+	// Merge brings rev into the current branch with strategy. When it stops
+	// at conflicts, Conflicts lists them until Continue or Abort.
+	Merge(rev string, strategy PullStrategy) error
+	// Conflicts lists the paths a stopped merge or rebase could not combine
+	Conflicts() ([]string, error)
+	// ResolveConflict settles a conflicted path with one side, as git names
+	// them: during a rebase ours is the upstream and theirs the local commit
+	// being replayed
+	ResolveConflict(path string, theirs bool) error
+	// Continue finishes a merge or rebase once its conflicts are resolved. A
+	// rebase may stop again at the conflicts of its next commit.
+	Continue(strategy PullStrategy) error
+	// Abort gives up a stopped merge or rebase and restores the branch
+	Abort(strategy PullStrategy) error
+}
+
+// ChangeKind is how a path differs from the last commit
+type ChangeKind string
+
+const (
+	ChangeAdded     ChangeKind = "added"
+	ChangeModified  ChangeKind = "modified"
+	ChangeDeleted   ChangeKind = "deleted"
+	ChangeRenamed   ChangeKind = "renamed"
+	ChangeUntracked ChangeKind = "untracked"
+)
+
+// Change is a changed path, relative to the repository and separated by
+// slashes
+type Change struct {
+	Path string
+	Kind ChangeKind
+	From string // the original path of a renamed file
+}
+
+// Commit is a commit listed by Repo.Log
+type Commit struct {
+	Hash    string
+	Author  string
+	When    time.Time
+	Subject string // the first line of the message
+}
+
+// Repo returns the repository in the dots directory. Options.Git, or else
+// DOTS_GIT, picks the backend: exec runs the git command, builtin uses the
+// git implementation bundled with dots. By default git is used when it is
+// installed.
+func (m *Manager) Repo() (Repo, error) {
+	return m.openRepo(m.dir, m.opts.GitOutput)
+}
+
+func (m *Manager) openRepo(dir string, output io.Writer) (Repo, error) {
+	backend := m.opts.Git
+	if backend == "" {
+		backend = os.Getenv("DOTS_GIT")
+	}
+
+	var repo Repo
+	switch backend {
+	case "exec":
+		repo = &execRepo{dir: dir, output: output}
+	case "builtin":
+		repo = &goGitRepo{dir: dir, output: output}
+	case "":
+		if _, err := exec.LookPath("git"); err == nil {
+			repo = &execRepo{dir: dir, output: output}
+		} else {
+			repo = &goGitRepo{dir: dir, output: output}
+		}
+	default:
+		return nil, fmt.Errorf("invalid DOTS_GIT '%s', want exec or builtin", backend)
+	}
+
+	if m.opts.DryRun {
+		return &dryRunRepo{Repo: repo, dir: dir, m: m}, nil
+	}
+	return repo, nil
+}
+
+// dryRunRepo plans the git commands that would change the repository
+// instead of running them. Queries still run.
+type dryRunRepo struct {
+	Repo
+	dir string
+	m   *Manager
+}
+
+func (r *dryRunRepo) Init() error {
+	r.m.planGit(r.dir, []string{"init"})
+	return nil
+}
+
+func (r *dryRunRepo) Clone(url string) error {
+	r.m.planGit("", []string{"clone", url, r.dir})
+	return nil
+}
+
+func (r *dryRunRepo) AddAll() error {
+	r.m.planGit(r.dir, []string{"add", "-A"})
+	return nil
+}
+
+func (r *dryRunRepo) Add(paths []string) error {
+	r.m.planGit(r.dir, append([]string{"add", "-A", "--"}, paths...))
+	return nil
+}
+
+func (r *dryRunRepo) Commit(message string, paths []string) error {
+	r.m.planGit(r.dir, commitArgs(message, paths))
+	return nil
+}
+
+func (r *dryRunRepo) Push() error {
+	r.m.planGit(r.dir, []string{"push"})
+	return nil
+}
+
+func (r *dryRunRepo) Stash(message string) error {
+	r.m.planGit(r.dir, []string{"stash", "push", "--include-untracked", "-m", message})
+	return nil
+}
+
+func (r *dryRunRepo) StashPop() error {
+	r.m.planGit(r.dir, []string{"stash", "pop"})
+	return nil
+}
+
+func (r *dryRunRepo) Fetch() error {
+	r.m.planGit(r.dir, []string{"fetch", "origin"})
+	return nil
+}
+
+func (r *dryRunRepo) Merge(rev string, strategy PullStrategy) error {
+	r.m.planGit(r.dir, mergeArgs(rev, strategy))
+	return nil
+}
+
+func (r *dryRunRepo) ResolveConflict(path string, theirs bool) error {
+	r.m.planGit(r.dir, []string{"checkout", conflictSide(theirs), "--", path})
+	return nil
+}
+
+func (r *dryRunRepo) Continue(strategy PullStrategy) error {
+	r.m.planGit(r.dir, continueArgs(strategy))
+	return nil
+}
+
+func (r *dryRunRepo) Abort(strategy PullStrategy) error {
+	r.m.planGit(r.dir, abortArgs(strategy))
+	return nil
+}
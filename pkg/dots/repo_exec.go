@@ -0,0 +1,341 @@
+package dots
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execRepo runs the git command
+type execRepo struct {
+	dir    string
+	output io.Writer
+}
+
+// run runs git in the repository and returns its output. The output is
+// part of the error when git fails.
+func (r *execRepo) run(args ...string) ([]byte, error) {
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = r.dir
+	output, err := gitCmd.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// stream runs git in dir with its output going to the output writer
+func (r *execRepo) stream(dir string, args ...string) error {
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = dir
+	gitCmd.Stdout = r.output
+	gitCmd.Stderr = r.output
+	return gitCmd.Run()
+}
+
+func (r *execRepo) Init() error {
+	_, err := r.run("init")
+	return err
+}
+
+func (r *execRepo) Clone(url string) error {
+	return r.stream("", "clone", url, r.dir)
+}
+
+func (r *execRepo) Status() ([]Change, error) {
+	gitCmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all")
+	gitCmd.Dir = r.dir
+	output, err := gitCmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePorcelain(string(output)), nil
+}
+
+// parsePorcelain parses the output of git status --porcelain -z. Entries are
+// "XY path", renames and copies are followed by their original path.
+func parsePorcelain(output string) []Change {
+	var changes []Change
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		code, path := entry[:2], entry[3:]
+		var from string
+
+		kind := ChangeModified
+		switch {
+		case code == "??":
+			kind = ChangeUntracked
+		case strings.ContainsAny(code, "RC"):
+			kind = ChangeRenamed
+			i++
+			if i < len(entries) {
+				from = entries[i]
+			}
+		case strings.Contains(code, "D"):
+			kind = ChangeDeleted
+		case strings.Contains(code, "A"):
+			kind = ChangeAdded
+		}
+		changes = append(changes, Change{Path: path, Kind: kind, From: from})
+	}
+	return changes
+}
+
+func (r *execRepo) AddAll() error {
+	_, err := r.run("add", "-A")
+	return err
+}
+
+func (r *execRepo) Add(paths []string) error {
+	_, err := r.run(append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+func (r *execRepo) Commit(message string, paths []string) error {
+	_, err := r.run(commitArgs(message, paths)...)
+	return err
+}
+
+// commitArgs are the git arguments committing paths, or the whole index
+// without any
+func commitArgs(message string, paths []string) []string {
+	args := []string{"commit", "-m", message}
+	if len(paths) > 0 {
+		args = append(append(args, "--only", "--"), paths...)
+	}
+	return args
+}
+
+func (r *execRepo) RemoteURL() (string, error) {
+	gitCmd := exec.Command("git", "remote", "get-url", "origin")
+	gitCmd.Dir = r.dir
+	output, err := gitCmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimSpace(string(output))
+	if url == "" {
+		return "", fmt.Errorf("origin has no URL")
+	}
+	return url, nil
+}
+
+func (r *execRepo) Push() error {
+	return r.stream(r.dir, "push")
+}
+
+func (r *execRepo) Stash(message string) error {
+	_, err := r.run("stash", "push", "--include-untracked", "-m", message)
+	return err
+}
+
+func (r *execRepo) StashPop() error {
+	_, err := r.run("stash", "pop")
+	return err
+}
+
+func (r *execRepo) Fetch() error {
+	return r.stream(r.dir, "fetch", "origin")
+}
+
+func (r *execRepo) Upstream() (string, error) {
+	output, err := r.run("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r *execRepo) MergeBase(a, b string) (string, error) {
+	output, err := r.run("merge-base", a, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r *execRepo) Log(from, to string) ([]Commit, error) {
+	output, err := r.run("log", "-z", "--format=%H%x1f%an%x1f%at%x1f%s", from+".."+to)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, entry := range strings.Split(string(output), "\x00") {
+		fields := strings.Split(strings.TrimSpace(entry), "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		seconds, _ := strconv.ParseInt(fields[2], 10, 64)
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			When:    time.Unix(seconds, 0),
+			Subject: fields[3],
+		})
+	}
+	return commits, nil
+}
+
+func (r *execRepo) Diff(w io.Writer, from, to string) error {
+	args := []string{"diff", "--no-color", "--no-ext-diff", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	if err := r.diff(w, args...); err != nil {
+		return err
+	}
+	if to != "" {
+		return nil
+	}
+
+	// git diff leaves out untracked files, show them as added
+	output, err := r.run("ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path == "" {
+			continue
+		}
+		if err := r.diff(w, "diff", "--no-color", "--no-ext-diff", "--no-index", "--", os.DevNull, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diff runs a git diff command writing to w. Exit status 1 only means that
+// differences were found.
+func (r *execRepo) diff(w io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = r.dir
+	gitCmd.Stdout = w
+	gitCmd.Stderr = &stderr
+	err := gitCmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (r *execRepo) Changes(from, to string) ([]Change, error) {
+	output, err := r.run("diff", "--name-status", "-z", "-M", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are a status followed by the path, renames by both their
+	// original and their new path
+	var changes []Change
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		code, path := fields[i], fields[i+1]
+		change := Change{Path: path, Kind: ChangeModified}
+		switch {
+		case strings.HasPrefix(code, "R") && i+2 < len(fields):
+			change = Change{Path: fields[i+2], Kind: ChangeRenamed, From: path}
+			i++
+		case code == "A":
+			change.Kind = ChangeAdded
+		case code == "D":
+			change.Kind = ChangeDeleted
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (r *execRepo) Export(rev, dir string) error {
+	// A throwaway index keeps the real one untouched
+	index, err := os.CreateTemp("", "dots-index-*")
+	if err != nil {
+		return err
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"read-tree", rev},
+		{"checkout-index", "--all", "--prefix=" + absDir + string(filepath.Separator)},
+	} {
+		gitCmd := exec.Command("git", args...)
+		gitCmd.Dir = r.dir
+		gitCmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+index.Name())
+		if output, err := gitCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+func (r *execRepo) Merge(rev string, strategy PullStrategy) error {
+	return r.stream(r.dir, mergeArgs(rev, strategy)...)
+}
+
+func (r *execRepo) Conflicts() ([]string, error) {
+	output, err := r.run("diff", "--name-only", "--diff-filter=U", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func (r *execRepo) ResolveConflict(path string, theirs bool) error {
+	// A side that deleted the file has no version to check out
+	if _, err := r.run("checkout", conflictSide(theirs), "--", path); err != nil {
+		_, err = r.run("rm", "--quiet", "--", path)
+		return err
+	}
+	_, err := r.run("add", "--", path)
+	return err
+}
+
+func (r *execRepo) Continue(strategy PullStrategy) error {
+	args := continueArgs(strategy)
+	if strategy == PullRebase {
+		// A commit the resolutions emptied has nothing left to replay
+		if _, err := r.run("diff", "--cached", "--quiet"); err == nil {
+			args = []string{"rebase", "--skip"}
+		}
+	}
+
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = r.dir
+	gitCmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	gitCmd.Stdout = r.output
+	gitCmd.Stderr = r.output
+	return gitCmd.Run()
+}
+
+func (r *execRepo) Abort(strategy PullStrategy) error {
+	_, err := r.run(abortArgs(strategy)...)
+	return err
+}
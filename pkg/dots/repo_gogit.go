@@ -0,0 +1,559 @@
+package dots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// errNoStash is returned by the built-in backend, which cannot stash
+var errNoStash = errors.New("the built-in git backend cannot stash changes, commit them with 'dots sync' first or install git")
+
+// errNoMerge is returned by the built-in backend, which can only fast-forward
+var errNoMerge = errors.New("the built-in git backend can only fast-forward, install git to rebase or merge")
+
+// goGitRepo is the built-in backend, implemented with go-git. It needs no git
+// installation. SSH remotes authenticate through ssh-agent.
+type goGitRepo struct {
+	dir    string
+	output io.Writer
+}
+
+func (r *goGitRepo) open() (*git.Repository, error) {
+	return git.PlainOpen(r.dir)
+}
+
+func (r *goGitRepo) worktree() (*git.Worktree, error) {
+	repo, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return repo.Worktree()
+}
+
+func (r *goGitRepo) Init() error {
+	_, err := git.PlainInit(r.dir, false)
+	return err
+}
+
+func (r *goGitRepo) Clone(url string) error {
+	_, err := git.PlainClone(r.dir, false, &git.CloneOptions{URL: url, Progress: r.output})
+	return err
+}
+
+func (r *goGitRepo) Status() ([]Change, error) {
+	repo, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for path, file := range status {
+		var kind ChangeKind
+		switch {
+		case file.Worktree == git.Untracked:
+			kind = ChangeUntracked
+		case file.Staging == git.Renamed || file.Worktree == git.Renamed:
+			kind = ChangeRenamed
+		case file.Staging == git.Deleted || file.Worktree == git.Deleted:
+			kind = ChangeDeleted
+		case file.Staging == git.Added:
+			kind = ChangeAdded
+		case file.Staging != git.Unmodified || file.Worktree != git.Unmodified:
+			kind = ChangeModified
+		default:
+			continue
+		}
+		change := Change{Path: path, Kind: kind}
+		if kind == ChangeRenamed {
+			change.From = file.Extra
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return r.pairRenames(repo, status, changes)
+}
+
+// pairRenames turns a staged deletion and a staged addition of the same
+// content into a rename. go-git reports them separately, where git detects
+// the rename.
+func (r *goGitRepo) pairRenames(repo *git.Repository, status git.Status, changes []Change) ([]Change, error) {
+	var added, deleted []int
+	for i, change := range changes {
+		switch status[change.Path].Staging {
+		case git.Added:
+			added = append(added, i)
+		case git.Deleted:
+			deleted = append(deleted, i)
+		}
+	}
+	if len(added) == 0 || len(deleted) == 0 {
+		return changes, nil
+	}
+
+	tree, err := r.tree(repo, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	deletedBlobs := map[plumbing.Hash]int{}
+	for _, i := range deleted {
+		if file, err := tree.File(changes[i].Path); err == nil {
+			deletedBlobs[file.Hash] = i
+		}
+	}
+
+	renamed := map[int]bool{}
+	for _, i := range added {
+		entry, err := index.Entry(changes[i].Path)
+		if err != nil {
+			continue
+		}
+		if j, ok := deletedBlobs[entry.Hash]; ok {
+			changes[i].Kind, changes[i].From = ChangeRenamed, changes[j].Path
+			renamed[j] = true
+			delete(deletedBlobs, entry.Hash)
+		}
+	}
+
+	paired := changes[:0]
+	for i, change := range changes {
+		if !renamed[i] {
+			paired = append(paired, change)
+		}
+	}
+	return paired, nil
+}
+
+func (r *goGitRepo) AddAll() error {
+	wt, err := r.worktree()
+	if err != nil {
+		return err
+	}
+	return wt.AddWithOptions(&git.AddOptions{All: true})
+}
+
+func (r *goGitRepo) Add(paths []string) error {
+	wt, err := r.worktree()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		// Add stages a deleted file as removed
+		if _, err := wt.Add(filepath.FromSlash(path)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (r *goGitRepo) Commit(message string, paths []string) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		// The author comes from the git configuration, like with git itself
+		_, err = wt.Commit(message, &git.CommitOptions{})
+		return err
+	}
+
+	// go-git commits the whole index, so commit one holding the last
+	// commit with only paths updated and put the full index back after
+	staged, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	partial, err := r.partialIndex(repo, staged, paths)
+	if err != nil {
+		return err
+	}
+	if err := repo.Storer.SetIndex(partial); err != nil {
+		return err
+	}
+	_, err = wt.Commit(message, &git.CommitOptions{})
+	if restoreErr := repo.Storer.SetIndex(staged); err == nil {
+		err = restoreErr
+	}
+	return err
+}
+
+// partialIndex returns the index of HEAD with the entries of paths taken
+// from staged
+func (r *goGitRepo) partialIndex(repo *git.Repository, staged *index.Index, paths []string) (*index.Index, error) {
+	only := map[string]bool{}
+	for _, path := range paths {
+		only[path] = true
+	}
+
+	partial := &index.Index{Version: staged.Version}
+	if tree, err := r.tree(repo, "HEAD"); err == nil {
+		err := tree.Files().ForEach(func(file *object.File) error {
+			if !only[file.Name] {
+				partial.Entries = append(partial.Entries, &index.Entry{Name: file.Name, Hash: file.Hash, Mode: file.Mode})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, err
+	}
+	for _, entry := range staged.Entries {
+		if only[entry.Name] {
+			partial.Entries = append(partial.Entries, entry)
+		}
+	}
+	sort.Slice(partial.Entries, func(i, j int) bool { return partial.Entries[i].Name < partial.Entries[j].Name })
+	return partial, nil
+}
+
+func (r *goGitRepo) RemoteURL() (string, error) {
+	repo, err := r.open()
+	if err != nil {
+		return "", err
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+	if urls := remote.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("origin has no URL")
+}
+
+func (r *goGitRepo) Push() error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	err = repo.Push(&git.PushOptions{RemoteName: "origin", Progress: r.output})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		fmt.Fprintln(r.output, "Everything up-to-date")
+		return nil
+	}
+	return err
+}
+
+func (r *goGitRepo) Stash(message string) error {
+	return errNoStash
+}
+
+func (r *goGitRepo) StashPop() error {
+	return errNoStash
+}
+
+func (r *goGitRepo) Fetch() error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	err = repo.Fetch(&git.FetchOptions{RemoteName: "origin", Progress: r.output})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+func (r *goGitRepo) Upstream() (string, error) {
+	repo, err := r.open()
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+	branch, ok := cfg.Branches[head.Name().Short()]
+	if !ok || branch.Remote == "" || branch.Merge == "" {
+		return "", fmt.Errorf("no upstream configured for branch %s", head.Name().Short())
+	}
+	return branch.Remote + "/" + branch.Merge.Short(), nil
+}
+
+// commit resolves a revision such as HEAD or origin/main to its commit
+func (r *goGitRepo) commit(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rev, err)
+	}
+	return repo.CommitObject(*hash)
+}
+
+func (r *goGitRepo) MergeBase(a, b string) (string, error) {
+	repo, err := r.open()
+	if err != nil {
+		return "", err
+	}
+	commitA, err := r.commit(repo, a)
+	if err != nil {
+		return "", err
+	}
+	commitB, err := r.commit(repo, b)
+	if err != nil {
+		return "", err
+	}
+	bases, err := commitA.MergeBase(commitB)
+	if err != nil {
+		return "", err
+	}
+	if len(bases) == 0 {
+		return "", fmt.Errorf("%s and %s have no common history", a, b)
+	}
+	return bases[0].Hash.String(), nil
+}
+
+func (r *goGitRepo) Log(from, to string) ([]Commit, error) {
+	repo, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	fromCommit, err := r.commit(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := r.commit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything reachable from from is left out
+	seen := map[plumbing.Hash]bool{}
+	iter := object.NewCommitPreorderIter(fromCommit, nil, nil)
+	if err := iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	iter = object.NewCommitPreorderIter(toCommit, seen, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		commits = append(commits, Commit{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			When:    c.Author.When,
+			Subject: subject,
+		})
+		return nil
+	})
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].When.After(commits[j].When) })
+	return commits, err
+}
+
+func (r *goGitRepo) Diff(w io.Writer, from, to string) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	fromCommit, err := r.commit(repo, from)
+	if err != nil {
+		return err
+	}
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return err
+	}
+
+	if to != "" {
+		toCommit, err := r.commit(repo, to)
+		if err != nil {
+			return err
+		}
+		toTree, err := toCommit.Tree()
+		if err != nil {
+			return err
+		}
+		patch, err := fromTree.Patch(toTree)
+		if err != nil {
+			return err
+		}
+		return encodePatch(w, patch)
+	}
+
+	// Compare the tree with the files in the working tree
+	changes, err := r.Status()
+	if err != nil {
+		return err
+	}
+	p := &patch{}
+	for _, change := range changes {
+		var from, to *patchFile
+		if file, err := fromTree.File(change.Path); err == nil {
+			content, err := file.Contents()
+			if err != nil {
+				return err
+			}
+			from = &patchFile{path: change.Path, content: []byte(content), mode: file.Mode}
+		}
+		if content, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(change.Path))); err == nil {
+			to = &patchFile{path: change.Path, content: content}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if from == nil && to == nil {
+			continue
+		}
+		p.files = append(p.files, newFilePatch(from, to))
+	}
+	return encodePatch(w, p)
+}
+
+func (r *goGitRepo) Changes(from, to string) ([]Change, error) {
+	repo, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	fromTree, err := r.tree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := r.tree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, diff := range diffs {
+		change := Change{Path: diff.To.Name, Kind: ChangeModified}
+		switch {
+		case diff.From.Name == "":
+			change.Kind = ChangeAdded
+		case diff.To.Name == "":
+			change = Change{Path: diff.From.Name, Kind: ChangeDeleted}
+		case diff.From.Name != diff.To.Name:
+			change.Kind, change.From = ChangeRenamed, diff.From.Name
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// tree resolves a revision to the tree of its commit
+func (r *goGitRepo) tree(repo *git.Repository, rev string) (*object.Tree, error) {
+	commit, err := r.commit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+func (r *goGitRepo) Export(rev, dir string) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	tree, err := r.tree(repo, rev)
+	if err != nil {
+		return err
+	}
+	return tree.Files().ForEach(func(file *object.File) error {
+		path := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		switch file.Mode {
+		case filemode.Symlink:
+			return os.Symlink(content, path)
+		case filemode.Executable:
+			return os.WriteFile(path, []byte(content), 0o755)
+		}
+		return os.WriteFile(path, []byte(content), 0o644)
+	})
+}
+
+func (r *goGitRepo) Merge(rev string, strategy PullStrategy) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	head, err := r.commit(repo, "HEAD")
+	if err != nil {
+		return err
+	}
+	target, err := r.commit(repo, rev)
+	if err != nil {
+		return err
+	}
+
+	// Whatever the strategy, only a fast-forward is possible
+	ok, err := head.IsAncestor(target)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNoMerge
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: target.Hash, Mode: git.MergeReset}); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.output, "Fast-forward to %s\n", target.Hash.String()[:7])
+	return nil
+}
+
+func (r *goGitRepo) Conflicts() ([]string, error) {
+	// Merge never stops at conflicts
+	return nil, nil
+}
+
+func (r *goGitRepo) ResolveConflict(path string, theirs bool) error {
+	return errNoMerge
+}
+
+func (r *goGitRepo) Continue(strategy PullStrategy) error {
+	return errNoMerge
+}
+
+func (r *goGitRepo) Abort(strategy PullStrategy) error {
+	// Merge never leaves anything to abort
+	return nil
+}
@@ -0,0 +1,430 @@
+package dots
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// backends opens a repository directory with each git backend
+var backends = []struct {
+	name string
+	open func(dir string) Repo
+}{
+	{"exec", func(dir string) Repo { return &execRepo{dir: dir, output: io.Discard} }},
+	{"builtin", func(dir string) Repo { return &goGitRepo{dir: dir, output: io.Discard} }},
+}
+
+// setupGit isolates git from the configuration of the user running the
+// tests and gives commits an author. It skips the test without git.
+func setupGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	writeTestFile(t, filepath.Join(home, ".gitconfig"), `[user]
+	name = Test
+	email = test@example.com
+[init]
+	defaultBranch = main
+[pull]
+	rebase = false
+`)
+}
+
+// runGit runs git in dir and returns its trimmed output
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = dir
+	output, err := gitCmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// newRemote creates a bare repository with a main branch holding the given
+// files in one commit
+func newRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	remote := filepath.Join(root, "remote.git")
+	runGit(t, root, "init", "--bare", "--initial-branch=main", remote)
+
+	seed := filepath.Join(root, "seed")
+	runGit(t, root, "clone", "--quiet", remote, seed)
+	commitFiles(t, seed, "Initial commit", files)
+	runGit(t, seed, "push", "--quiet", "-u", "origin", "main")
+	return remote
+}
+
+// cloneRemote clones remote with the git command into a new directory
+func cloneRemote(t *testing.T, remote string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "clone")
+	runGit(t, filepath.Dir(dir), "clone", "--quiet", remote, dir)
+	return dir
+}
+
+// commitFiles writes files into dir and commits them. An empty content
+// deletes the file.
+func commitFiles(t *testing.T, dir, message string, files map[string]string) string {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		writeTestFile(t, path, content)
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "--quiet", "-m", message)
+	return runGit(t, dir, "rev-parse", "HEAD")
+}
+
+// pushFrom commits files in a second clone of remote and pushes them
+func pushFrom(t *testing.T, remote, message string, files map[string]string) string {
+	t.Helper()
+	other := cloneRemote(t, remote)
+	hash := commitFiles(t, other, message, files)
+	runGit(t, other, "push", "--quiet", "origin", "main")
+	return hash
+}
+
+func sortChanges(changes []Change) []Change {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func TestRepoClone(t *testing.T) {
+	setupGit(t)
+	remote := newRemote(t, map[string]string{".bashrc": "alias ll='ls -l'\n"})
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "dots")
+			repo := backend.open(dir)
+			if err := repo.Clone(remote); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, filepath.Join(dir, ".bashrc")); got != "alias ll='ls -l'\n" {
+				t.Errorf(".bashrc holds %q after clone", got)
+			}
+			url, err := repo.RemoteURL()
+			if err != nil || url != remote {
+				t.Errorf("RemoteURL = %q, %v, want %q", url, err, remote)
+			}
+			upstream, err := repo.Upstream()
+			if err != nil || upstream != "origin/main" {
+				t.Errorf("Upstream = %q, %v, want origin/main", upstream, err)
+			}
+		})
+	}
+}
+
+func TestRepoStatus(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{
+				".bashrc":             "bash\n",
+				".vimrc":              "vim\n",
+				".config/git/config":  "[user]\n",
+				".config/kitty/theme": "dark\n",
+			})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+
+			changes, err := repo.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 0 {
+				t.Fatalf("clean clone has changes: %v", changes)
+			}
+
+			writeTestFile(t, filepath.Join(dir, ".bashrc"), "bash changed\n")
+			os.Remove(filepath.Join(dir, ".vimrc"))
+			writeTestFile(t, filepath.Join(dir, ".config", "nvim", "init.lua"), "-- new\n")
+			writeTestFile(t, filepath.Join(dir, "with space", "file name"), "x\n")
+			runGit(t, dir, "mv", ".config/kitty/theme", ".config/kitty/colors")
+			writeTestFile(t, filepath.Join(dir, ".config", "git", "ignore"), "*.log\n")
+			runGit(t, dir, "add", ".config/git/ignore")
+
+			changes, err = repo.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Change{
+				{Path: ".bashrc", Kind: ChangeModified},
+				{Path: ".config/git/ignore", Kind: ChangeAdded},
+				{Path: ".config/kitty/colors", Kind: ChangeRenamed, From: ".config/kitty/theme"},
+				{Path: ".config/nvim/init.lua", Kind: ChangeUntracked},
+				{Path: ".vimrc", Kind: ChangeDeleted},
+				{Path: "with space/file name", Kind: ChangeUntracked},
+			}
+			if got := sortChanges(changes); !reflect.DeepEqual(got, want) {
+				t.Errorf("Status =\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
+
+func TestRepoAddCommit(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n", ".vimrc": "vim\n"})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+
+			writeTestFile(t, filepath.Join(dir, ".bashrc"), "bash changed\n")
+			os.Remove(filepath.Join(dir, ".vimrc"))
+			writeTestFile(t, filepath.Join(dir, ".zshrc"), "zsh\n")
+
+			// Only the selected paths, the deletion included
+			if err := repo.Add([]string{".bashrc", ".vimrc"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Commit("Update bashrc, remove vimrc", nil); err != nil {
+				t.Fatal(err)
+			}
+			changes, err := repo.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Change{{Path: ".zshrc", Kind: ChangeUntracked}}
+			if !reflect.DeepEqual(changes, want) {
+				t.Errorf("Status after a partial commit = %v, want %v", changes, want)
+			}
+			if got := runGit(t, dir, "log", "-1", "--format=%s"); got != "Update bashrc, remove vimrc" {
+				t.Errorf("last commit is %q", got)
+			}
+
+			if err := repo.AddAll(); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Commit("Add zshrc", nil); err != nil {
+				t.Fatal(err)
+			}
+			if changes, err := repo.Status(); err != nil || len(changes) != 0 {
+				t.Errorf("Status after committing everything = %v, %v", changes, err)
+			}
+		})
+	}
+}
+
+func TestRepoChanges(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{
+				".bashrc":            "bash\n",
+				".vimrc":             "vim\n",
+				".config/nvim/init":  "a long enough line so the rename is detected\nand another\n",
+				".config/kitty/conf": "font_size 12\n",
+			})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+			base := runGit(t, dir, "rev-parse", "HEAD")
+
+			runGit(t, dir, "mv", ".config/nvim/init", ".config/nvim/init.lua")
+			commitFiles(t, dir, "Change things", map[string]string{
+				".bashrc": "bash changed\n",
+				".vimrc":  "",
+				".zshrc":  "zsh\n",
+			})
+
+			changes, err := repo.Changes(base, "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Change{
+				{Path: ".bashrc", Kind: ChangeModified},
+				{Path: ".config/nvim/init.lua", Kind: ChangeRenamed, From: ".config/nvim/init"},
+				{Path: ".vimrc", Kind: ChangeDeleted},
+				{Path: ".zshrc", Kind: ChangeAdded},
+			}
+			if got := sortChanges(changes); !reflect.DeepEqual(got, want) {
+				t.Errorf("Changes =\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
+
+func TestRepoFetchLog(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n"})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+			base := runGit(t, dir, "rev-parse", "HEAD")
+			local := commitFiles(t, dir, "Local change", map[string]string{".vimrc": "vim\n"})
+			first := pushFrom(t, remote, "First remote change", map[string]string{".zshrc": "zsh\n"})
+			second := pushFrom(t, remote, "Second remote change", map[string]string{".inputrc": "set bell-style none\n"})
+
+			if err := repo.Fetch(); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Fetch(); err != nil {
+				t.Errorf("Fetch when up to date: %v", err)
+			}
+			upstream, err := repo.Upstream()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			incoming, err := repo.Log("HEAD", upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var hashes []string
+			for _, c := range incoming {
+				hashes = append(hashes, c.Hash)
+			}
+			if want := []string{second, first}; !reflect.DeepEqual(hashes, want) {
+				t.Errorf("incoming = %v, want %v newest first", hashes, want)
+			} else if incoming[0].Subject != "Second remote change" || incoming[0].Author != "Test" || incoming[0].When.IsZero() {
+				t.Errorf("incoming[0] = %+v", incoming[0])
+			}
+
+			outgoing, err := repo.Log(upstream, "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outgoing) != 1 || outgoing[0].Hash != local {
+				t.Errorf("outgoing = %v, want %s", outgoing, local)
+			}
+
+			mergeBase, err := repo.MergeBase("HEAD", upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mergeBase != base {
+				t.Errorf("MergeBase = %s, want %s", mergeBase, base)
+			}
+
+			if none, err := repo.Log("HEAD", "HEAD"); err != nil || len(none) != 0 {
+				t.Errorf("Log of an empty range = %v, %v", none, err)
+			}
+		})
+	}
+}
+
+func TestRepoPush(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n"})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+
+			if err := repo.Push(); err != nil {
+				t.Errorf("Push when up to date: %v", err)
+			}
+			hash := commitFiles(t, dir, "Add vimrc", map[string]string{".vimrc": "vim\n"})
+			if err := repo.Push(); err != nil {
+				t.Fatal(err)
+			}
+			if got := runGit(t, remote, "rev-parse", "main"); got != hash {
+				t.Errorf("remote main is at %s after push, want %s", got, hash)
+			}
+		})
+	}
+}
+
+func TestRepoMergeFastForward(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n"})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+			hash := pushFrom(t, remote, "Update bashrc", map[string]string{".bashrc": "bash changed\n", ".vimrc": "vim\n"})
+			if err := repo.Fetch(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := repo.Merge("origin/main", PullFastForward); err != nil {
+				t.Fatal(err)
+			}
+			if got := runGit(t, dir, "rev-parse", "HEAD"); got != hash {
+				t.Errorf("HEAD is at %s, want %s", got, hash)
+			}
+			if got := readTestFile(t, filepath.Join(dir, ".bashrc")); got != "bash changed\n" {
+				t.Errorf(".bashrc holds %q after merge", got)
+			}
+			if got := readTestFile(t, filepath.Join(dir, ".vimrc")); got != "vim\n" {
+				t.Errorf(".vimrc holds %q after merge", got)
+			}
+			if changes, err := repo.Status(); err != nil || len(changes) != 0 {
+				t.Errorf("Status after merge = %v, %v", changes, err)
+			}
+		})
+	}
+}
+
+func TestRepoMergeDiverged(t *testing.T) {
+	setupGit(t)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n"})
+			dir := cloneRemote(t, remote)
+			repo := backend.open(dir)
+			local := commitFiles(t, dir, "Add vimrc", map[string]string{".vimrc": "vim\n"})
+			pushFrom(t, remote, "Add zshrc", map[string]string{".zshrc": "zsh\n"})
+			if err := repo.Fetch(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := repo.Merge("origin/main", PullMerge)
+			if backend.name == "builtin" {
+				// Only a fast-forward is possible, nothing may change
+				if !errors.Is(err, errNoMerge) {
+					t.Fatalf("Merge of diverged history = %v, want errNoMerge", err)
+				}
+				if got := runGit(t, dir, "rev-parse", "HEAD"); got != local {
+					t.Errorf("HEAD moved to %s", got)
+				}
+				if _, err := os.Stat(filepath.Join(dir, ".zshrc")); !os.IsNotExist(err) {
+					t.Errorf(".zshrc was checked out: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parents := runGit(t, dir, "log", "-1", "--format=%P"); len(strings.Fields(parents)) != 2 {
+				t.Errorf("HEAD has parents %q, want a merge commit", parents)
+			}
+			for _, name := range []string{".vimrc", ".zshrc"} {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s is missing after merge: %v", name, err)
+				}
+			}
+		})
+	}
+}
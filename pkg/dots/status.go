@@ -0,0 +1,256 @@
+package dots
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// LinkState describes how the target of a tracked dotfile looks on disk
+type LinkState string
+
+const (
+	StateOK          LinkState = "ok"            // the target is in place
+	StateMissing     LinkState = "missing"       // there is nothing at the target
+	StateWrongTarget LinkState = "wrong-target"  // the link points somewhere else
+	StateNotSymlink  LinkState = "not-a-symlink" // something else is in the way
+	StateOrphaned    LinkState = "orphaned"      // the source no longer exists
+	StateStale       LinkState = "stale"         // generated output or a copy is out of date
+	StateModified    LinkState = "modified"      // a copy was edited at its target
+)
+
+// StatusEntry is the status of a single tracked dotfile
+type StatusEntry struct {
+	State    LinkState  `json:"state"`
+	Target   string     `json:"target"`
+	Source   string     `json:"source"`
+	Link     string     `json:"link,omitempty"`
+	Rendered bool       `json:"rendered,omitempty"`
+	Mode     DeployMode `json:"mode,omitempty"`
+}
+
+// Status inspects the target of every tracked dotfile
+func (m *Manager) Status() ([]StatusEntry, error) {
+	if err := m.checkDir(); err != nil {
+		return nil, err
+	}
+
+	tracked, err := m.Tracked()
+	if err != nil {
+		return nil, err
+	}
+
+	var data *templateData
+	entries := make([]StatusEntry, 0, len(tracked))
+	for _, df := range tracked {
+		if df.IsEncrypted() {
+			entry, err := m.decryptStatus(df)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		if df.IsCopied() && !df.IsTemplate() {
+			entry, err := m.copyStatus(df)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		if !df.IsTemplate() {
+			entries = append(entries, linkStatus(df))
+			continue
+		}
+
+		if data == nil {
+			if data, err = m.loadTemplateData(); err != nil {
+				return nil, err
+			}
+		}
+		entry, err := renderStatus(df, data)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// renderStatus inspects the rendered output of a template dotfile
+func renderStatus(df Dotfile, data *templateData) (StatusEntry, error) {
+	entry := StatusEntry{Target: df.Target, Source: df.Source, Rendered: true}
+
+	if _, err := os.Stat(df.Source); os.IsNotExist(err) {
+		entry.State = StateOrphaned
+		return entry, nil
+	}
+
+	info, err := os.Lstat(df.Target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			entry.State = StateMissing
+			return entry, nil
+		}
+		entry.State = StateNotSymlink
+		return entry, nil
+	}
+	if !info.Mode().IsRegular() {
+		entry.State = StateWrongTarget
+		if link, err := os.Readlink(df.Target); err == nil {
+			entry.Link = link
+		}
+		return entry, nil
+	}
+
+	stale, err := renderStale(df, data)
+	if err != nil {
+		return entry, fmt.Errorf("%s: %w", df.Source, err)
+	}
+	if stale {
+		entry.State = StateStale
+	} else {
+		entry.State = StateOK
+	}
+	return entry, nil
+}
+
+// decryptStatus inspects the decrypted copy of an encrypted dotfile. Its
+// content is only compared when DOTS_PASSPHRASE is set, status never prompts.
+func (m *Manager) decryptStatus(df Dotfile) (StatusEntry, error) {
+	entry := StatusEntry{Target: df.Target, Source: df.Source, Rendered: true}
+
+	if _, err := os.Stat(df.Source); os.IsNotExist(err) {
+		entry.State = StateOrphaned
+		return entry, nil
+	}
+
+	info, err := os.Lstat(df.Target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			entry.State = StateMissing
+		} else {
+			entry.State = StateNotSymlink
+		}
+		return entry, nil
+	}
+	if !info.Mode().IsRegular() {
+		entry.State = StateWrongTarget
+		if link, err := os.Readlink(df.Target); err == nil {
+			entry.Link = link
+		}
+		return entry, nil
+	}
+
+	entry.State = StateOK
+	if info.Mode().Perm() != 0o600 {
+		entry.State = StateStale
+	} else if os.Getenv("DOTS_PASSPHRASE") != "" {
+		plaintext, err := m.decryptFile(df.Source)
+		if err != nil {
+			return entry, err
+		}
+		current, err := os.ReadFile(df.Target)
+		if err != nil {
+			return entry, err
+		}
+		if !bytes.Equal(plaintext, current) {
+			entry.State = StateStale
+		}
+	}
+	return entry, nil
+}
+
+// copyStatus inspects the copy or hard link of a dotfile. Content hashes tell
+// edits made at the target apart from changes to the source.
+func (m *Manager) copyStatus(df Dotfile) (StatusEntry, error) {
+	entry := StatusEntry{Target: df.Target, Source: df.Source, Mode: df.Mode}
+
+	srcInfo, err := os.Stat(df.Source)
+	if os.IsNotExist(err) {
+		entry.State = StateOrphaned
+		return entry, nil
+	}
+
+	info, err := os.Lstat(df.Target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			entry.State = StateMissing
+		} else {
+			entry.State = StateNotSymlink
+		}
+		return entry, nil
+	}
+	if !info.Mode().IsRegular() {
+		entry.State = StateWrongTarget
+		if link, err := os.Readlink(df.Target); err == nil {
+			entry.Link = link
+		}
+		return entry, nil
+	}
+	if df.Mode == ModeHardlink && os.SameFile(srcInfo, info) {
+		entry.State = StateOK
+		return entry, nil
+	}
+
+	targetHash, err := hashFile(df.Target)
+	if err != nil {
+		return entry, err
+	}
+	sourceHash, err := hashFile(df.Source)
+	if err != nil {
+		return entry, err
+	}
+	deployments, err := m.loadDeployments()
+	if err != nil {
+		return entry, err
+	}
+	record, recorded := deployments[df.Target]
+
+	switch {
+	case targetHash == sourceHash && df.Mode == ModeCopy:
+		entry.State = StateOK
+	case targetHash == sourceHash:
+		// Same content, but the hard link was broken
+		entry.State = StateStale
+	case !recorded || record.Hash != targetHash:
+		entry.State = StateModified
+	default:
+		entry.State = StateStale
+	}
+	return entry, nil
+}
+
+// linkStatus inspects the link of a single dotfile
+func linkStatus(df Dotfile) StatusEntry {
+	entry := StatusEntry{Target: df.Target, Source: df.Source}
+
+	link, err := os.Readlink(df.Target)
+	if err == nil {
+		entry.Link = link
+	}
+
+	if _, err := os.Stat(df.Source); os.IsNotExist(err) {
+		entry.State = StateOrphaned
+		return entry
+	}
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			entry.State = StateMissing
+		} else {
+			entry.State = StateNotSymlink
+		}
+		return entry
+	}
+
+	if ResolveLink(df.Target, link) == df.Source {
+		entry.State = StateOK
+	} else {
+		entry.State = StateWrongTarget
+	}
+	return entry
+}
@@ -0,0 +1,193 @@
+package dots
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyncOptions configure Sync
+type SyncOptions struct {
+	// Message is the commit message, by default CommitMessage describes the
+	// committed changes
+	Message string
+	// Select picks the changes to commit, the others stay uncommitted.
+	// Without it every change is committed.
+	Select func(changes []Change) ([]Change, error)
+}
+
+// SyncResult is the outcome of Sync
+type SyncResult struct {
+	Changes   []Change // what was committed, empty when there was nothing to sync or nothing was selected
+	Skipped   []Change // the changes Select left out
+	Message   string   // the commit message
+	Committed bool
+	Pushed    bool // false without a remote, see NoRemote
+	NoRemote  bool
+}
+
+// Sync commits every change in the dots directory and pushes it to origin.
+// Without a remote the commit stays local. The pre-sync hooks run before the
+// changes are collected, so they can still add some, the post-sync hooks
+// only run once something was committed.
+func (m *Manager) Sync(opts SyncOptions) (*SyncResult, error) {
+	if err := m.checkRepo(); err != nil {
+		return nil, err
+	}
+	if err := m.runHooks(HookPreSync, nil); err != nil {
+		return nil, err
+	}
+
+	repo, err := m.Repo()
+	if err != nil {
+		return nil, err
+	}
+
+	m.log.Infof("Checking git status...")
+
+	// Check if there are any changes
+	changes, err := repo.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %w", err)
+	}
+
+	result := &SyncResult{Changes: changes, Message: opts.Message}
+	if len(changes) == 0 {
+		return result, nil
+	}
+
+	if opts.Select != nil {
+		selected, err := opts.Select(changes)
+		if err != nil {
+			return nil, err
+		}
+		result.Changes, result.Skipped = selected, skippedChanges(changes, selected)
+		if len(selected) == 0 {
+			return result, nil
+		}
+	}
+
+	m.log.Infof("Changes detected. Staging files...")
+
+	// Stage the changes, all of them unless some were left out. Then only
+	// those are committed, even when a skipped one was staged before.
+	var paths []string
+	if len(result.Skipped) == 0 {
+		err = repo.AddAll()
+	} else {
+		paths = changePaths(result.Changes)
+		err = repo.Add(stagePaths(result.Changes))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage files: %w", err)
+	}
+	m.logf("✓ Files staged")
+
+	// Generate commit message if not provided
+	if result.Message == "" {
+		result.Message = CommitMessage(result.Changes)
+	}
+
+	subject, _, _ := strings.Cut(result.Message, "\n")
+	m.log.Infof("Committing with message: \"%s\"", subject)
+
+	// Commit changes
+	if err := repo.Commit(result.Message, paths); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	result.Committed = true
+	m.logf("✓ Changes committed")
+
+	// Check if remote is configured
+	if _, err := repo.RemoteURL(); err != nil {
+		result.NoRemote = true
+	} else {
+		m.log.Infof("Pushing to remote...")
+
+		// Push to remote
+		if err := repo.Push(); err != nil {
+			return nil, fmt.Errorf("failed to push: %w", err)
+		}
+		result.Pushed = true
+	}
+
+	changed := make([]string, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		changed = append(changed, change.Path)
+	}
+	m.runHooks(HookPostSync, changed)
+	return result, nil
+}
+
+// skippedChanges returns the changes that are not in selected
+func skippedChanges(changes, selected []Change) []Change {
+	picked := map[string]bool{}
+	for _, change := range selected {
+		picked[change.Path] = true
+	}
+	var skipped []Change
+	for _, change := range changes {
+		if !picked[change.Path] {
+			skipped = append(skipped, change)
+		}
+	}
+	return skipped
+}
+
+// stagePaths lists the paths to stage for changes. A rename is staged
+// already, its original path is gone from the index.
+func stagePaths(changes []Change) []string {
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	return paths
+}
+
+// changePaths lists the paths to commit for changes, renamed files are
+// committed under both names
+func changePaths(changes []Change) []string {
+	var paths []string
+	for _, change := range changes {
+		if change.From != "" {
+			paths = append(paths, change.From)
+		}
+		paths = append(paths, change.Path)
+	}
+	return paths
+}
+
+// Push pushes the committed changes to origin. Uncommitted changes are an
+// error, Sync commits them first.
+func (m *Manager) Push() error {
+	if err := m.checkRepo(); err != nil {
+		return err
+	}
+
+	repo, err := m.Repo()
+	if err != nil {
+		return err
+	}
+
+	// Check if remote is configured
+	if _, err := repo.RemoteURL(); err != nil {
+		return fmt.Errorf("%w\nAdd a remote with: cd %s && git remote add origin <url>", ErrNoRemote, m.dir)
+	}
+
+	// Check for uncommitted changes
+	changes, err := repo.Status()
+	if err != nil {
+		return fmt.Errorf("failed to check git status: %w", err)
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%w\nUse 'dots sync' to commit and push, or commit manually first", ErrUncommitted)
+	}
+
+	m.log.Infof("Pushing to remote...")
+
+	// Push to remote
+	if err := repo.Push(); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package dots
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncSelectedOnly(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			setupGit(t)
+			remote := newRemote(t, map[string]string{".bashrc": "bash\n", ".vimrc": "vim\n", ".inputrc": "input\n"})
+			m := newTestManager(t, Options{Git: backend.name})
+			runGit(t, m.home, "clone", "--quiet", remote, m.dir)
+
+			writeTestFile(t, filepath.Join(m.dir, ".bashrc"), "bash changed\n")
+			writeTestFile(t, filepath.Join(m.dir, ".zshrc"), "zsh\n")
+			runGit(t, m.dir, "mv", ".inputrc", ".editrc")
+			// A skipped change that was staged before
+			writeTestFile(t, filepath.Join(m.dir, ".vimrc"), "vim changed\n")
+			runGit(t, m.dir, "add", ".vimrc")
+
+			result, err := m.Sync(SyncOptions{
+				Select: func(changes []Change) ([]Change, error) {
+					var selected []Change
+					for _, change := range changes {
+						if change.Path != ".vimrc" {
+							selected = append(selected, change)
+						}
+					}
+					return selected, nil
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Committed || len(result.Skipped) != 1 || result.Skipped[0].Path != ".vimrc" {
+				t.Fatalf("result = %+v", result)
+			}
+
+			committed := runGit(t, m.dir, "show", "--name-status", "--format=", "-M", "HEAD")
+			if want := "M\t.bashrc\nR100\t.inputrc\t.editrc\nA\t.zshrc"; committed != want {
+				t.Errorf("committed:\n%s\nwant\n%s", committed, want)
+			}
+			if got := runGit(t, m.dir, "show", "HEAD:.vimrc"); got != "vim" {
+				t.Errorf("the committed .vimrc holds %q", got)
+			}
+			if staged := runGit(t, m.dir, "diff", "--cached", "--name-only"); staged != ".vimrc" {
+				t.Errorf("staged after sync: %q, want .vimrc", staged)
+			}
+			if got := readTestFile(t, filepath.Join(m.dir, ".vimrc")); got != "vim changed\n" {
+				t.Errorf(".vimrc holds %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package dots
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateExt marks dotfiles that are rendered instead of linked
+const templateExt = ".tmpl"
+
+// varsName is the machine-local file in the state directory holding user
+// defined template variables. It never lives in the dots directory, so it
+// is never committed.
+const varsName = "vars.yaml"
+
+// templateData is what templates are executed with
+type templateData struct {
+	Hostname string
+	OS       string
+	Arch     string
+	User     string
+	Home     string
+	Profile  string
+	Profiles []string
+	Vars     map[string]any
+}
+
+// IsTemplate reports whether the dotfile is rendered rather than linked
+func (df Dotfile) IsTemplate() bool {
+	if df.Entry != nil && df.Entry.Template {
+		return true
+	}
+	return strings.HasSuffix(df.Source, templateExt)
+}
+
+// IsEncrypted reports whether the dotfile is decrypted rather than linked
+func (df Dotfile) IsEncrypted() bool {
+	if df.Entry != nil && df.Entry.Encrypted {
+		return true
+	}
+	return strings.HasSuffix(df.Source, encryptedExt)
+}
+
+// loadTemplateData gathers the built-in machine variables together with the
+// user defined ones from vars.yaml
+func (m *Manager) loadTemplateData() (*templateData, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine hostname: %w", err)
+	}
+
+	username := os.Getenv("USER")
+	if usr, err := user.Current(); err == nil {
+		username = usr.Username
+	}
+
+	manifest, err := loadManifest(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &Manifest{}
+	}
+	profiles, err := manifest.activeProfiles(m.opts.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &templateData{
+		Hostname: hostname,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		User:     username,
+		Home:     m.home,
+		Profile:  profiles[len(profiles)-1],
+		Profiles: profiles,
+		Vars:     map[string]any{},
+	}
+
+	varsPath := filepath.Join(m.stateDir, varsName)
+	raw, err := os.ReadFile(varsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", varsPath, err)
+	}
+	if err := yaml.Unmarshal(raw, &data.Vars); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", varsPath, err)
+	}
+	if data.Vars == nil {
+		data.Vars = map[string]any{}
+	}
+
+	return data, nil
+}
+
+// renderTemplate executes the template at src. Referencing an undefined
+// variable is an error rather than silently rendering "<no value>".
+func renderTemplate(src string, data *templateData) ([]byte, error) {
+	text, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(src)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": os.Getenv}).
+		Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// renderStale reports whether the rendered output at the target differs
+// from what the template produces now
+func renderStale(df Dotfile, data *templateData) (bool, error) {
+	rendered, err := renderTemplate(df.Source, data)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := os.ReadFile(df.Target)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(rendered, current), nil
+}
+
+// deployTemplate renders the dotfile and writes the result to its target.
+// It reports whether the target changed.
+func (m *Manager) deployTemplate(df Dotfile, data *templateData) (bool, error) {
+	srcInfo, err := os.Stat(df.Source)
+	if err != nil {
+		return false, err
+	}
+	if srcInfo.IsDir() {
+		return false, fmt.Errorf("templates must be files: %s", df.Source)
+	}
+
+	rendered, err := renderTemplate(df.Source, data)
+	if err != nil {
+		return false, err
+	}
+
+	return m.writeDeployed(df.Target, rendered, srcInfo.Mode().Perm())
+}
@@ -0,0 +1,338 @@
+package dots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// journalName is the file in the state directory that records the steps of
+// the running transaction. It doubles as a lock: only one transaction can be
+// in flight at a time, and it names the process holding it.
+const journalName = "journal.json"
+
+// Step operations recorded in the journal
+const (
+	stepCreate = "create" // Path was created and is removed on rollback
+	stepMove   = "move"   // From was renamed to Path and is moved back on rollback
+)
+
+type txStep struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+}
+
+// transaction applies a series of filesystem changes that are undone as a
+// whole if any of them fails. Every step is journaled before it runs, so an
+// interrupted run can be rolled back by the next invocation.
+type transaction struct {
+	Name      string   `json:"name"`
+	Started   string   `json:"started"`
+	Pid       int      `json:"pid,omitempty"`
+	Host      string   `json:"host,omitempty"`
+	Steps     []txStep `json:"steps"`
+	Committed bool     `json:"committed"`
+
+	journal string
+	m       *Manager
+}
+
+// beginTransaction creates the journal for a new transaction
+func (m *Manager) beginTransaction(name string) (*transaction, error) {
+	host, _ := os.Hostname()
+	tx := &transaction{
+		Name:    name,
+		Started: time.Now().Format(time.RFC3339),
+		Pid:     os.Getpid(),
+		Host:    host,
+		m:       m,
+	}
+	if m.opts.DryRun {
+		return tx, nil
+	}
+
+	if err := os.MkdirAll(m.stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Linking the written journal into place creates it atomically, so
+	// Recover never sees it empty
+	tx.journal = filepath.Join(m.stateDir, journalName)
+	tmp, err := tx.write()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	if err := os.Link(tmp, tx.journal); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%w (journal: %s)", ErrBusy, tx.journal)
+		}
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+	return tx, nil
+}
+
+// run executes fn inside the transaction. If fn fails every recorded step is
+// rolled back, otherwise the transaction is committed.
+func (tx *transaction) run(fn func() error) error {
+	if err := fn(); err != nil {
+		if tx.m.opts.DryRun {
+			return err
+		}
+		tx.m.log.Infof("Rolling back changes...")
+		if rbErr := tx.rollback(); rbErr != nil {
+			return fmt.Errorf("%w\nrollback failed: %v\nJournal kept at %s", err, rbErr, tx.journal)
+		}
+		tx.m.log.Infof("✓ Rolled back")
+		return err
+	}
+	return tx.commit()
+}
+
+// save writes the journal atomically and flushes it to disk
+func (tx *transaction) save() error {
+	tmp, err := tx.write()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, tx.journal); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// write writes the journal to a new file next to it and returns its path
+func (tx *transaction) write() (string, error) {
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(tx.journal), journalName+".*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write journal: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write journal: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// running reports whether the process that owns the journal may still be
+// at work. A journal written on another host is assumed to be in use.
+func (tx *transaction) running() bool {
+	if tx.Pid == 0 {
+		return false
+	}
+	if host, _ := os.Hostname(); tx.Host != host {
+		return true
+	}
+	process, err := os.FindProcess(tx.Pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks that the process exists
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// record journals a step before it is executed
+func (tx *transaction) record(step txStep) error {
+	if tx.m.opts.DryRun {
+		return nil
+	}
+	tx.Steps = append(tx.Steps, step)
+	return tx.save()
+}
+
+// mkdirAll creates path and any missing parents. Only the topmost directory
+// that did not exist is recorded, removing it undoes the whole chain.
+func (tx *transaction) mkdirAll(path string) error {
+	missing := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = dir
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if missing == "" {
+		return nil
+	}
+
+	if err := tx.record(txStep{Op: stepCreate, Path: missing}); err != nil {
+		return err
+	}
+	return tx.m.mkdirAll(path, 0o755)
+}
+
+// copy copies a file or directory to dst, which must not exist yet
+func (tx *transaction) copy(src, dst string) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: dst}); err != nil {
+		return err
+	}
+	return tx.m.copyPath(src, dst, nil)
+}
+
+// copyExcept copies a directory to dst like copy, leaving out what skip matches
+func (tx *transaction) copyExcept(src, dst string, skip skipFunc) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: dst}); err != nil {
+		return err
+	}
+	return tx.m.copyPath(src, dst, skip)
+}
+
+// writeFile creates a new file at path with the given content
+func (tx *transaction) writeFile(path string, data []byte, perm os.FileMode) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: path}); err != nil {
+		return err
+	}
+	return tx.m.writeFile(path, data, perm)
+}
+
+// symlink creates a symlink at newname pointing to oldname
+func (tx *transaction) symlink(oldname, newname string) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: newname}); err != nil {
+		return err
+	}
+	return tx.m.symlink(oldname, newname)
+}
+
+// hardlink creates a hard link at newname to oldname
+func (tx *transaction) hardlink(oldname, newname string) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: newname}); err != nil {
+		return err
+	}
+	return tx.m.hardlink(oldname, newname)
+}
+
+// rename moves oldpath to newpath, which must not exist yet. Rolling back
+// removes newpath, oldpath is expected to be a disposable staging copy.
+func (tx *transaction) rename(oldpath, newpath string) error {
+	if err := tx.record(txStep{Op: stepCreate, Path: newpath}); err != nil {
+		return err
+	}
+	return tx.m.rename(oldpath, newpath)
+}
+
+// remove moves path aside next to itself. The aside copy is only deleted
+// once the transaction commits, so a rollback can put it back.
+func (tx *transaction) remove(path string) error {
+	aside := fmt.Sprintf("%s.dots-%d", path, time.Now().UnixNano())
+	if err := tx.record(txStep{Op: stepMove, From: path, Path: aside}); err != nil {
+		return err
+	}
+	return tx.m.rename(path, aside)
+}
+
+// commit deletes everything that was moved aside and clears the journal
+func (tx *transaction) commit() error {
+	if tx.m.opts.DryRun {
+		return nil
+	}
+
+	tx.Committed = true
+	if err := tx.save(); err != nil {
+		return err
+	}
+	return tx.finish()
+}
+
+// finish removes the aside copies of a committed transaction
+func (tx *transaction) finish() error {
+	for _, step := range tx.Steps {
+		if step.Op != stepMove {
+			continue
+		}
+		if err := os.RemoveAll(step.Path); err != nil {
+			return fmt.Errorf("failed to clean up %s: %w", step.Path, err)
+		}
+	}
+	return os.Remove(tx.journal)
+}
+
+// rollback undoes the recorded steps in reverse order. Steps that never got
+// to run are skipped.
+func (tx *transaction) rollback() error {
+	var errs []error
+	for i := len(tx.Steps) - 1; i >= 0; i-- {
+		step := tx.Steps[i]
+		switch step.Op {
+		case stepCreate:
+			if err := os.RemoveAll(step.Path); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove %s: %w", step.Path, err))
+			}
+		case stepMove:
+			if _, err := os.Lstat(step.Path); os.IsNotExist(err) {
+				continue
+			}
+			// Clear whatever took its place before moving the original back
+			if err := os.RemoveAll(step.From); err != nil {
+				errs = append(errs, fmt.Errorf("failed to clear %s: %w", step.From, err))
+				continue
+			}
+			if err := os.Rename(step.Path, step.From); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore %s: %w", step.From, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return os.Remove(tx.journal)
+}
+
+// Recover finishes or rolls back a transaction left behind by an interrupted
+// run. Call it before any other operation. While the process that started
+// the transaction is still running, it returns ErrBusy and leaves it alone.
+func (m *Manager) Recover() error {
+	journal := filepath.Join(m.stateDir, journalName)
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	tx := &transaction{journal: journal, m: m}
+	if err := json.Unmarshal(data, tx); err != nil {
+		return fmt.Errorf("corrupt journal at %s: %w", journal, err)
+	}
+	if tx.running() {
+		return fmt.Errorf("%w: '%s' from %s by process %d on %s\nRemove %s if that process is gone", ErrBusy, tx.Name, tx.Started, tx.Pid, tx.Host, journal)
+	}
+
+	if m.opts.DryRun {
+		m.planf("recover interrupted '%s' from %s", tx.Name, tx.Started)
+		return nil
+	}
+
+	if tx.Committed {
+		m.log.Infof("Finishing interrupted '%s' from %s...", tx.Name, tx.Started)
+		return tx.finish()
+	}
+
+	m.log.Infof("Rolling back interrupted '%s' from %s...", tx.Name, tx.Started)
+	if err := tx.rollback(); err != nil {
+		return fmt.Errorf("failed to recover: %w\nJournal kept at %s", err, journal)
+	}
+	m.log.Infof("✓ Rolled back")
+	return nil
+}
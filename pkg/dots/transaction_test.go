@@ -0,0 +1,264 @@
+package dots
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// leftovers lists the aside copies tx.remove left in dir
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.dots-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestTransactionRollback(t *testing.T) {
+	m := newTestManager(t, Options{})
+	target := filepath.Join(m.home, ".bashrc")
+	writeTestFile(t, target, "original\n")
+	created := filepath.Join(m.home, ".config", "app", "config")
+
+	tx, err := m.beginTransaction("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("step failed")
+	err = tx.run(func() error {
+		if err := tx.remove(target); err != nil {
+			return err
+		}
+		if err := tx.mkdirAll(filepath.Dir(created)); err != nil {
+			return err
+		}
+		if err := tx.writeFile(created, []byte("new\n"), 0o644); err != nil {
+			return err
+		}
+		if err := tx.symlink(created, target); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("run returned %v, want %v", err, failure)
+	}
+
+	if info, err := os.Lstat(target); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("%s was not restored as a file: %v", target, err)
+	}
+	if got := readTestFile(t, target); got != "original\n" {
+		t.Errorf("%s holds %q after rollback, want the original", target, got)
+	}
+	if _, err := os.Lstat(filepath.Join(m.home, ".config")); !os.IsNotExist(err) {
+		t.Errorf("created directories were not removed: %v", err)
+	}
+	if left := leftovers(t, m.home); len(left) > 0 {
+		t.Errorf("aside copies left behind: %v", left)
+	}
+	if _, err := os.Stat(filepath.Join(m.stateDir, journalName)); !os.IsNotExist(err) {
+		t.Errorf("journal was not removed: %v", err)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	m := newTestManager(t, Options{})
+	target := filepath.Join(m.home, ".bashrc")
+	writeTestFile(t, target, "original\n")
+
+	tx, err := m.beginTransaction("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.run(func() error {
+		if err := tx.remove(target); err != nil {
+			return err
+		}
+		return tx.writeFile(target, []byte("replaced\n"), 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestFile(t, target); got != "replaced\n" {
+		t.Errorf("%s holds %q, want the replacement", target, got)
+	}
+	if left := leftovers(t, m.home); len(left) > 0 {
+		t.Errorf("aside copies left behind: %v", left)
+	}
+	if _, err := os.Stat(filepath.Join(m.stateDir, journalName)); !os.IsNotExist(err) {
+		t.Errorf("journal was not removed: %v", err)
+	}
+}
+
+func TestTransactionBusy(t *testing.T) {
+	m := newTestManager(t, Options{})
+	tx, err := m.beginTransaction("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.beginTransaction("second"); !errors.Is(err, ErrBusy) {
+		t.Fatalf("second transaction returned %v, want ErrBusy", err)
+	}
+	// Another invocation must not roll back a transaction still at work
+	if err := m.Recover(); !errors.Is(err, ErrBusy) {
+		t.Fatalf("Recover during a transaction returned %v, want ErrBusy", err)
+	}
+
+	if err := tx.run(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = m.beginTransaction("third")
+	if err != nil {
+		t.Fatalf("transaction after commit: %v", err)
+	}
+	tx.run(func() error { return nil })
+
+	if entries, _ := os.ReadDir(m.stateDir); len(entries) > 0 {
+		t.Errorf("state directory not cleaned up: %v", entries)
+	}
+}
+
+// writeJournal leaves a journal behind as an interrupted run would
+func writeJournal(t *testing.T, m *Manager, tx transaction) {
+	t.Helper()
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(m.stateDir, journalName), string(data))
+}
+
+func TestRecoverRollsBack(t *testing.T) {
+	m := newTestManager(t, Options{})
+	target := filepath.Join(m.home, ".bashrc")
+	aside := target + ".dots-1"
+	created := filepath.Join(m.home, ".vimrc")
+	writeTestFile(t, aside, "original\n")
+	writeTestFile(t, target, "half done\n")
+	writeTestFile(t, created, "new\n")
+	writeJournal(t, m, transaction{
+		Name: "link",
+		Steps: []txStep{
+			{Op: stepMove, From: target, Path: aside},
+			{Op: stepCreate, Path: created},
+			// Never got to run
+			{Op: stepMove, From: filepath.Join(m.home, ".zshrc"), Path: filepath.Join(m.home, ".zshrc.dots-2")},
+		},
+	})
+
+	if err := m.Recover(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestFile(t, target); got != "original\n" {
+		t.Errorf("%s holds %q after recovery, want the original", target, got)
+	}
+	if _, err := os.Lstat(created); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed: %v", created, err)
+	}
+	if left := leftovers(t, m.home); len(left) > 0 {
+		t.Errorf("aside copies left behind: %v", left)
+	}
+	if _, err := os.Stat(filepath.Join(m.stateDir, journalName)); !os.IsNotExist(err) {
+		t.Errorf("journal was not removed: %v", err)
+	}
+	if err := m.Recover(); err != nil {
+		t.Errorf("Recover without a journal: %v", err)
+	}
+}
+
+func TestRecoverFinishesCommitted(t *testing.T) {
+	m := newTestManager(t, Options{})
+	target := filepath.Join(m.home, ".bashrc")
+	aside := target + ".dots-1"
+	writeTestFile(t, aside, "original\n")
+	writeTestFile(t, target, "replaced\n")
+	writeJournal(t, m, transaction{
+		Name:      "link",
+		Steps:     []txStep{{Op: stepMove, From: target, Path: aside}},
+		Committed: true,
+	})
+
+	if err := m.Recover(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestFile(t, target); got != "replaced\n" {
+		t.Errorf("%s holds %q, a committed transaction must be kept", target, got)
+	}
+	if left := leftovers(t, m.home); len(left) > 0 {
+		t.Errorf("aside copies left behind: %v", left)
+	}
+}
+
+func TestRecoverOwner(t *testing.T) {
+	// A process that has exited
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exited := exec.Command(exe, "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name string
+		pid  int
+		host string
+		busy bool
+	}{
+		{"running", os.Getpid(), host, true},
+		{"on another host", exited.Process.Pid, host + ".other", true},
+		{"exited", exited.Process.Pid, host, false},
+		{"unknown", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, Options{})
+			target := filepath.Join(m.home, ".bashrc")
+			aside := target + ".dots-1"
+			writeTestFile(t, aside, "original\n")
+			writeTestFile(t, target, "half done\n")
+			writeJournal(t, m, transaction{
+				Name:  "link",
+				Pid:   tt.pid,
+				Host:  tt.host,
+				Steps: []txStep{{Op: stepMove, From: target, Path: aside}},
+			})
+
+			err := m.Recover()
+			if !tt.busy {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readTestFile(t, target); got != "original\n" {
+					t.Errorf("%s holds %q, want the interrupted run rolled back", target, got)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrBusy) {
+				t.Fatalf("Recover = %v, want ErrBusy", err)
+			}
+			if got := readTestFile(t, target); got != "half done\n" {
+				t.Errorf("%s holds %q, the running transaction was touched", target, got)
+			}
+			if got := readTestFile(t, aside); got != "original\n" {
+				t.Errorf("%s holds %q", aside, got)
+			}
+			if _, err := os.Stat(filepath.Join(m.stateDir, journalName)); err != nil {
+				t.Errorf("journal was removed: %v", err)
+			}
+		})
+	}
+}